@@ -15,9 +15,13 @@ import (
 	halkyon "halkyon.io/api"
 	"halkyon.io/operator-framework"
 	capability2 "halkyon.io/operator-framework/plugins/capability"
+	tektonv1beta1 "halkyon.io/operator/pkg/apis/tekton/v1beta1"
 	"halkyon.io/operator/pkg/controller/capability"
 	"halkyon.io/operator/pkg/controller/component"
+	"halkyon.io/operator/pkg/push"
+	"halkyon.io/operator/pkg/webhook"
 	"io/ioutil"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -37,6 +41,21 @@ const (
 	// HalkyonPluginsEnvVar holds the name of the env variable defining which plugins need to be downloaded as a comma-separated
 	// list of values following the <github org>/<github project>@<version> format e.g. halkyonio/postgresql-capability@v1.0.0-beta.3
 	HalkyonPluginsEnvVar = "HALKYON_PLUGINS"
+	// WebhookSecretEnvVar holds the name of the env variable containing the secret git servers use to sign push webhooks
+	// If left empty, the webhook receiver is not started
+	WebhookSecretEnvVar = "WEBHOOK_SECRET"
+	// WebhookAddressEnvVar holds the name of the env variable containing the address the webhook receiver listens on
+	WebhookAddressEnvVar = "WEBHOOK_ADDRESS"
+	// PushAddressEnvVar holds the name of the env variable containing the address the dev mode push endpoint listens on
+	// If set to an empty value, the push endpoint is not started
+	PushAddressEnvVar = "PUSH_ADDRESS"
+	// PushTLSDirEnvVar holds the name of the env variable containing the directory holding the tls.crt certificate and
+	// tls.key private key the dev mode push endpoint serves TLS with, e.g. mounted from a kubernetes.io/tls secret
+	// The push endpoint is not started if they can't be found
+	PushTLSDirEnvVar = "PUSH_TLS_DIR"
+	// PushMaxSizeEnvVar holds the name of the env variable containing the maximum size of the archives pushed to dev mode
+	// components, as a quantity such as 100Mi
+	PushMaxSizeEnvVar = "PUSH_MAX_SIZE"
 )
 
 var (
@@ -94,6 +113,7 @@ func main() {
 
 	// check if we run on OpenShift early so that things are initialized for DependentResources which might depend on it
 	framework.InitHelper(mgr)
+	component.InitClients(config)
 
 	// Setup Scheme for all resources
 	log.Info("Registering Halkyon resources")
@@ -180,6 +200,51 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Start the git push webhook receiver if a secret to verify payloads is provided
+	if secret, found := os.LookupEnv(WebhookSecretEnvVar); found && len(secret) > 0 {
+		address, found := os.LookupEnv(WebhookAddressEnvVar)
+		if !found {
+			address = ":8090"
+		}
+		if err := mgr.Add(webhook.NewServer(address, []byte(secret), mgr.GetClient(), logf.Log.WithName("webhook"))); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	} else {
+		log.Info("no " + WebhookSecretEnvVar + " provided, git push webhooks are disabled")
+	}
+
+	// Start the endpoint receiving the code pushed to dev mode components unless explicitly disabled
+	if address, found := os.LookupEnv(PushAddressEnvVar); !found || len(address) > 0 {
+		if !found {
+			address = ":8091"
+		}
+		dir, found := os.LookupEnv(PushTLSDirEnvVar)
+		if !found {
+			dir = "/etc/halkyon/push-tls"
+		}
+		maxSize := push.DefaultMaxSize
+		if value, found := os.LookupEnv(PushMaxSizeEnvVar); found && len(value) > 0 {
+			quantity, err := resource.ParseQuantity(value)
+			if err != nil || quantity.Value() <= 0 {
+				log.Error(err, "invalid "+PushMaxSizeEnvVar+" provided: "+value)
+				os.Exit(1)
+			}
+			maxSize = quantity.Value()
+		}
+		cert, key := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+		if _, err := os.Stat(cert); err != nil {
+			log.Info("no TLS certificate found in " + dir + ", dev mode push endpoint is disabled")
+		} else if _, err := os.Stat(key); err != nil {
+			log.Info("no TLS private key found in " + dir + ", dev mode push endpoint is disabled")
+		} else if err := mgr.Add(push.NewServer(address, cert, key, maxSize, config, mgr.GetClient(), logf.Log.WithName("push"))); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	} else {
+		log.Info("empty " + PushAddressEnvVar + " provided, dev mode push endpoint is disabled")
+	}
+
 	// Start the Cmd
 	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
 		log.Error(err, "Manager exited non-zero")
@@ -198,9 +263,14 @@ func registerAdditionalResources(m manager.Manager) {
 	if err := route.Install(scheme); err != nil {
 		log.Error(err, "")
 	}
+	// registering the Tekton types only maps them to their kinds, without querying the cluster, and is thus harmless when
+	// Tekton isn't installed: the Tekton dependents aren't watched then and builds run as Jobs
 	if err := tektonv1.AddToScheme(scheme); err != nil {
 		log.Error(err, "")
 	}
+	if err := tektonv1beta1.AddToScheme(scheme); err != nil {
+		log.Error(err, "")
+	}
 	if err := image.Install(scheme); err != nil {
 		log.Error(err, "")
 	}
@@ -0,0 +1,34 @@
+// Package v1beta1 provides the subset of the Tekton Pipelines tekton.dev/v1beta1 API used by the operator to generate
+// builds and pipelines, the Tekton version we depend on only providing the v1alpha1 API.
+// +k8s:deepcopy-gen=package
+// +groupName=tekton.dev
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchemeGroupVersion is the group version used to register these objects
+var SchemeGroupVersion = schema.GroupVersion{Group: "tekton.dev", Version: "v1beta1"}
+
+var (
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	AddToScheme   = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&Task{},
+		&TaskList{},
+		&TaskRun{},
+		&TaskRunList{},
+		&Pipeline{},
+		&PipelineList{},
+		&PipelineRun{},
+		&PipelineRunList{},
+	)
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
@@ -0,0 +1,209 @@
+package v1beta1
+
+import (
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+// Params, steps, step states and task references have the same shape in both API versions so the v1alpha1 types are
+// reused for them.
+type (
+	Param     = v1alpha1.Param
+	ParamSpec = v1alpha1.ParamSpec
+	Step      = v1alpha1.Step
+	StepState = v1alpha1.StepState
+	TaskRef   = v1alpha1.TaskRef
+)
+
+// Task is a collection of sequential steps run as a pod
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type Task struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TaskSpec `json:"spec"`
+}
+
+// TaskSpec defines the parameters, workspaces, steps and results of a Task
+type TaskSpec struct {
+	Description string                 `json:"description,omitempty"`
+	Params      []ParamSpec            `json:"params,omitempty"`
+	Workspaces  []WorkspaceDeclaration `json:"workspaces,omitempty"`
+	Steps       []Step                 `json:"steps,omitempty"`
+	Volumes     []corev1.Volume        `json:"volumes,omitempty"`
+	Results     []TaskResult           `json:"results,omitempty"`
+}
+
+// WorkspaceDeclaration declares a volume a Task expects to be provided by its TaskRuns
+type WorkspaceDeclaration struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MountPath   string `json:"mountPath,omitempty"`
+	ReadOnly    bool   `json:"readOnly,omitempty"`
+}
+
+// TaskResult declares a result a Task writes under /tekton/results
+type TaskResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// TaskList contains a list of Tasks
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type TaskList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Task `json:"items"`
+}
+
+// TaskRun runs a Task with the specified parameters and workspaces
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type TaskRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TaskRunSpec   `json:"spec,omitempty"`
+	Status TaskRunStatus `json:"status,omitempty"`
+}
+
+// TaskRunSpec defines the Task to run along with its parameters and workspaces
+type TaskRunSpec struct {
+	Params             []Param            `json:"params,omitempty"`
+	ServiceAccountName string             `json:"serviceAccountName,omitempty"`
+	TaskRef            *TaskRef           `json:"taskRef,omitempty"`
+	Timeout            *metav1.Duration   `json:"timeout,omitempty"`
+	Workspaces         []WorkspaceBinding `json:"workspaces,omitempty"`
+}
+
+// WorkspaceBinding provides the volume backing a workspace declared by the Task
+type WorkspaceBinding struct {
+	Name                  string                                    `json:"name"`
+	SubPath               string                                    `json:"subPath,omitempty"`
+	PersistentVolumeClaim *corev1.PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
+	EmptyDir              *corev1.EmptyDirVolumeSource              `json:"emptyDir,omitempty"`
+	ConfigMap             *corev1.ConfigMapVolumeSource             `json:"configMap,omitempty"`
+	Secret                *corev1.SecretVolumeSource                `json:"secret,omitempty"`
+}
+
+// TaskRunStatus defines the observed state of a TaskRun
+type TaskRunStatus struct {
+	duckv1beta1.Status `json:",inline"`
+
+	PodName        string          `json:"podName"`
+	StartTime      *metav1.Time    `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time    `json:"completionTime,omitempty"`
+	Steps          []StepState     `json:"steps,omitempty"`
+	TaskRunResults []TaskRunResult `json:"taskResults,omitempty"`
+}
+
+// TaskRunResult is a result written by a step of the TaskRun
+type TaskRunResult struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// TaskRunList contains a list of TaskRuns
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type TaskRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TaskRun `json:"items"`
+}
+
+// Pipeline is a graph of Tasks run in the order specified by their dependencies
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type Pipeline struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PipelineSpec `json:"spec"`
+}
+
+// PipelineSpec defines the parameters, workspaces and Tasks of a Pipeline
+type PipelineSpec struct {
+	Description string                         `json:"description,omitempty"`
+	Params      []ParamSpec                    `json:"params,omitempty"`
+	Workspaces  []PipelineWorkspaceDeclaration `json:"workspaces,omitempty"`
+	Tasks       []PipelineTask                 `json:"tasks,omitempty"`
+}
+
+// PipelineWorkspaceDeclaration declares a workspace the PipelineRuns have to provide to the Tasks of the Pipeline
+type PipelineWorkspaceDeclaration struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PipelineTask runs either a referenced or an embedded Task as part of a Pipeline
+type PipelineTask struct {
+	Name       string                         `json:"name"`
+	TaskRef    *TaskRef                       `json:"taskRef,omitempty"`
+	TaskSpec   *TaskSpec                      `json:"taskSpec,omitempty"`
+	RunAfter   []string                       `json:"runAfter,omitempty"`
+	Params     []Param                        `json:"params,omitempty"`
+	Workspaces []WorkspacePipelineTaskBinding `json:"workspaces,omitempty"`
+}
+
+// WorkspacePipelineTaskBinding binds a workspace declared by a Task to a workspace of the Pipeline
+type WorkspacePipelineTaskBinding struct {
+	Name      string `json:"name"`
+	Workspace string `json:"workspace"`
+	SubPath   string `json:"subPath,omitempty"`
+}
+
+// PipelineList contains a list of Pipelines
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PipelineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Pipeline `json:"items"`
+}
+
+// PipelineRun runs a Pipeline with the specified parameters and workspaces
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PipelineRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PipelineRunSpec   `json:"spec,omitempty"`
+	Status PipelineRunStatus `json:"status,omitempty"`
+}
+
+// PipelineRunSpec defines the Pipeline to run along with its parameters and workspaces
+type PipelineRunSpec struct {
+	PipelineRef        *PipelineRef       `json:"pipelineRef,omitempty"`
+	Params             []Param            `json:"params,omitempty"`
+	ServiceAccountName string             `json:"serviceAccountName,omitempty"`
+	Timeout            *metav1.Duration   `json:"timeout,omitempty"`
+	Workspaces         []WorkspaceBinding `json:"workspaces,omitempty"`
+}
+
+// PipelineRef references a Pipeline by name
+type PipelineRef struct {
+	Name string `json:"name,omitempty"`
+}
+
+// PipelineRunStatus defines the observed state of a PipelineRun
+type PipelineRunStatus struct {
+	duckv1beta1.Status `json:",inline"`
+
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// TaskRuns maps the names of the TaskRuns created by the PipelineRun to their status
+	TaskRuns map[string]*PipelineRunTaskRunStatus `json:"taskRuns,omitempty"`
+}
+
+// PipelineRunTaskRunStatus is the status of a TaskRun created for a Task of the Pipeline
+type PipelineRunTaskRunStatus struct {
+	PipelineTaskName string         `json:"pipelineTaskName,omitempty"`
+	Status           *TaskRunStatus `json:"status,omitempty"`
+}
+
+// PipelineRunList contains a list of PipelineRuns
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PipelineRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PipelineRun `json:"items"`
+}
@@ -0,0 +1,69 @@
+package component
+
+import (
+	"encoding/json"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+// Annotations used to configure aspects of a Component that its API doesn't expose (yet). Their value is JSON-encoded.
+const (
+	// EnvRefsAnnotation holds a JSON array of env vars using valueFrom to reference secrets, config maps, fields or resources
+	EnvRefsAnnotation = "halkyon.io/env-refs"
+	// ResourcesAnnotation holds the compute resources requirements (requests and limits) of a Component's containers. When
+	// set on a Runtime, it provides the defaults for components using this runtime.
+	ResourcesAnnotation = "halkyon.io/resources"
+	// ProbesAnnotation holds the liveness, readiness and startup probes of a Component's runtime container. When set on a
+	// Runtime, it provides the defaults for components using this runtime.
+	ProbesAnnotation = "halkyon.io/probes"
+	// DevModeAnnotation holds the supervisord commands, and the paths, a Runtime uses to build and run the code pushed to dev
+	// mode Components
+	DevModeAnnotation = "halkyon.io/dev-mode"
+	// DebugAnnotation holds the debug port, and whether the application waits for a debugger, of a dev mode Component
+	// whose application runs with the debug agent of its runtime
+	DebugAnnotation = "halkyon.io/debug"
+	// LastPushAnnotation holds the outcome of the latest push of code to a dev mode Component through the operator's push
+	// endpoint. It is set by the operator.
+	LastPushAnnotation = "halkyon.io/last-push"
+	// ScalingAnnotation holds the replicas or autoscaling configuration of a build mode Component
+	ScalingAnnotation = "halkyon.io/scaling"
+	// BuildTriggerAnnotation holds an arbitrary value which, when changed, triggers a new build of a build mode Component
+	BuildTriggerAnnotation = "halkyon.io/build-trigger"
+	// RegistryAnnotation holds the credentials secret, CA config map and TLS verification setting used to push the image of a
+	// build mode Component
+	RegistryAnnotation = "halkyon.io/registry"
+	// BuildCacheAnnotation holds the configuration of the persistent cache used by the builds of a build mode Component
+	BuildCacheAnnotation = "halkyon.io/build-cache"
+	// GitAnnotation holds the credentials secret, submodules and clone depth settings used to clone the sources of a build
+	// mode Component
+	GitAnnotation = "halkyon.io/git"
+	// DockerfileAnnotation holds the path, relative to the context path, of the Dockerfile a build mode Component using the
+	// dockerfile build type is built with
+	DockerfileAnnotation = "halkyon.io/dockerfile"
+	// RootlessBuildAnnotation holds whether the image of a build mode Component is built without privileges
+	RootlessBuildAnnotation = "halkyon.io/rootless-build"
+	// PipelineAnnotation holds the ordered stages, and their settings, of the pipeline building a build mode Component
+	// instead of a single Task
+	PipelineAnnotation = "halkyon.io/pipeline"
+	// RolloutAnnotation holds the strategy, and its settings, used to roll out new images of a build mode Component
+	RolloutAnnotation = "halkyon.io/rollout"
+	// RolloutAdvanceAnnotation holds a counter which, when incremented, advances the manually promoted rollout of a build
+	// mode Component by one step
+	RolloutAdvanceAnnotation = "halkyon.io/rollout-advance"
+	// RollbackAnnotation holds the digest of a previously deployed image a build mode Component is rolled back to
+	RollbackAnnotation = "halkyon.io/rollback"
+)
+
+// unmarshalAnnotation decodes the JSON value of the specified annotation into target, returning false if the annotation
+// isn't set on the given object
+func unmarshalAnnotation(object metav1.Object, key string, target interface{}) (bool, error) {
+	value, ok := object.GetAnnotations()[key]
+	if !ok || len(strings.TrimSpace(value)) == 0 {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(value), target); err != nil {
+		return true, fmt.Errorf("invalid '%s' annotation on '%s': %s", key, object.GetName(), err.Error())
+	}
+	return true, nil
+}
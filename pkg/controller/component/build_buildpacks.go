@@ -0,0 +1,90 @@
+package component
+
+import (
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// buildpacksReport is where the lifecycle writes the report of the export, which holds the digest of the pushed image
+const buildpacksReport = "/layers/report.toml"
+
+// buildpacksStrategy builds and pushes the image using Cloud Native Buildpacks, the buildpacks being provided by the
+// builder image
+type buildpacksStrategy struct{}
+
+func init() {
+	registerBuildStrategy("buildpacks", buildpacksStrategy{})
+}
+
+func (buildpacksStrategy) taskName() string {
+	return "buildpacks-build-push"
+}
+
+func (buildpacksStrategy) taskSpec() v1alpha1.TaskSpec {
+	spec := buildTaskSpec(
+		stringParamSpec("builderImage", "gcr.io/paketo-buildpacks/builder:base", "The Cloud Native Buildpacks builder image"),
+	)
+	mounts := []corev1.VolumeMount{
+		{Name: "layers", MountPath: "/layers"},
+		{Name: "buildpacks-cache", MountPath: "/cache"},
+	}
+	spec.Steps = []v1alpha1.Step{
+		{Container: corev1.Container{
+			// Give the builder user ownership of the sources and the directories the lifecycle writes to
+			Name:         "prepare",
+			Image:        "alpine",
+			Command:      []string{"chown"},
+			Args:         []string{"-R", "1000:1000", "$(inputs.params.workspacePath)", "/layers", "/cache"},
+			VolumeMounts: mounts,
+		}},
+		{Container: corev1.Container{
+			// Detect, build, export and push the image using the buildpacks lifecycle
+			Name:    "create",
+			Image:   "$(inputs.params.builderImage)",
+			Command: []string{"/cnb/lifecycle/creator"},
+			Args: []string{
+				"-app=$(inputs.params.workspacePath)/$(inputs.params.contextPath)",
+				"-layers=/layers",
+				"-cache-dir=/cache",
+				"-uid=1000",
+				"-gid=1000",
+				"-report=" + buildpacksReport,
+				"$(outputs.resources.image.url)",
+			},
+			Env: []corev1.EnvVar{
+				{Name: "DOCKER_CONFIG", Value: "/home/builder/.docker"},
+			},
+			VolumeMounts: mounts,
+		}},
+		{Container: corev1.Container{
+			// Report the digest of the pushed image, as recorded by the lifecycle, since the builder image may not provide a
+			// shell
+			Name:    "report-digest",
+			Image:   "alpine",
+			Command: []string{"/bin/sh", "-c"},
+			Args: []string{
+				`sed -n 's/^ *digest *= *"\(.*\)"/\1/p' ` + buildpacksReport + ` > ` + digestFile + ` && test -s ` + digestFile + ` && ` +
+					reportDigestCommand,
+			},
+			TerminationMessagePath: stepResultsPath,
+			VolumeMounts:           mounts,
+		}},
+	}
+	spec.Volumes = []corev1.Volume{
+		{Name: "layers", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		{Name: "buildpacks-cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	}
+	return spec
+}
+
+func (buildpacksStrategy) taskRunParams(c *v1beta1.Component) []v1alpha1.Param {
+	params := []v1alpha1.Param{
+		stringParam("contextPath", contextPath(c)),
+	}
+	// the base image is interpreted as the builder image for buildpacks
+	if len(c.Spec.BuildConfig.BaseImage) > 0 {
+		params = append(params, stringParam("builderImage", c.Spec.BuildConfig.BaseImage))
+	}
+	return params
+}
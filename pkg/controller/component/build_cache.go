@@ -0,0 +1,266 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sort"
+	"strings"
+)
+
+// Scopes and cleanup policies of build caches
+const (
+	componentCacheScope = "component"
+	namespaceCacheScope = "namespace"
+	deleteCacheCleanup  = "delete"
+	retainCacheCleanup  = "retain"
+)
+
+const (
+	buildCacheVolume        = "build-cache"
+	buildCacheDir           = "/cache"
+	defaultBuildCacheSize   = "2Gi"
+	defaultMaxUsagePercent  = 90
+	namespaceBuildCacheName = "halkyon-build-cache"
+	// mavenRepositoryVolume and mavenRepositoryPath identify where Maven builds store their local repository
+	mavenRepositoryVolume = "m2-repository"
+	mavenRepositoryPath   = "/var/cache/m2"
+)
+
+// cachedVolumes associates the names of the Task volumes which content is kept by the build cache to the cache sub
+// directory holding it
+var cachedVolumes = map[string]string{
+	"libcontainers":       "containers",
+	rootlessStorageVolume: "containers-vfs",
+	mavenRepositoryVolume: "m2",
+	"buildpacks-cache":    "buildpacks",
+}
+
+// containerStorageVolumes are the cached volumes holding the buildah container storage, which can't be used by concurrent
+// builds and is thus only kept by component caches
+var containerStorageVolumes = map[string]bool{
+	"libcontainers":       true,
+	rootlessStorageVolume: true,
+}
+
+var mavenRepositoryMount = corev1.VolumeMount{Name: mavenRepositoryVolume, MountPath: mavenRepositoryPath}
+
+// mavenRepositoryVolumeFor returns the volume holding the Maven repository, which is only kept between builds if the
+// component uses a build cache
+func mavenRepositoryVolumeFor() corev1.Volume {
+	return corev1.Volume{Name: mavenRepositoryVolume, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}
+}
+
+// buildCacheConfig defines the persistent cache used by the builds of a component
+type buildCacheConfig struct {
+	// Scope is either "component" (default) for a cache dedicated to the component or "namespace" for a cache shared by
+	// all the components of the namespace
+	Scope string `json:"scope,omitempty"`
+	// Size is the requested size of the cache volume, which cannot be changed once it is created
+	Size string `json:"size,omitempty"`
+	// StorageClass is the storage class of the cache volume, only used when it is created. Namespace caches are mounted by
+	// concurrent builds, possibly on different nodes, and thus need a storage class supporting ReadWriteMany volumes.
+	StorageClass string `json:"storageClass,omitempty"`
+	// MaxUsagePercent is the usage of the cache volume above which the cache is wiped before building
+	MaxUsagePercent int `json:"maxUsagePercent,omitempty"`
+	// Cleanup is either "delete" (default) to delete the cache along with the component or "retain" to keep it. Namespace
+	// caches are always retained.
+	Cleanup string `json:"cleanup,omitempty"`
+}
+
+// getBuildCacheConfig returns the build cache configuration of the specified component, if it uses one
+func getBuildCacheConfig(c *v1beta1.Component) (config buildCacheConfig, enabled bool, err error) {
+	if v1beta1.BuildDeploymentMode != c.Spec.DeploymentMode {
+		return config, false, nil
+	}
+	enabled, err = unmarshalAnnotation(c, BuildCacheAnnotation, &config)
+	if err != nil || !enabled {
+		return config, false, err
+	}
+	if len(config.Scope) == 0 {
+		config.Scope = componentCacheScope
+	}
+	if config.MaxUsagePercent == 0 {
+		config.MaxUsagePercent = defaultMaxUsagePercent
+	}
+	if len(config.Cleanup) == 0 {
+		config.Cleanup = deleteCacheCleanup
+	}
+
+	if config.Scope != componentCacheScope && config.Scope != namespaceCacheScope {
+		return config, true, fmt.Errorf("unknown '%s' build cache scope, known scopes: %s,%s", config.Scope, componentCacheScope, namespaceCacheScope)
+	}
+	if config.Cleanup != deleteCacheCleanup && config.Cleanup != retainCacheCleanup {
+		return config, true, fmt.Errorf("unknown '%s' build cache cleanup policy, known policies: %s,%s", config.Cleanup, deleteCacheCleanup, retainCacheCleanup)
+	}
+	if _, err := resource.ParseQuantity(config.Size); len(config.Size) > 0 && err != nil {
+		return config, true, fmt.Errorf("invalid '%s' build cache size: %s", config.Size, err.Error())
+	}
+	if config.MaxUsagePercent < 0 || config.MaxUsagePercent > 100 {
+		return config, true, fmt.Errorf("build cache maxUsagePercent must be between 0 and 100, was %d", config.MaxUsagePercent)
+	}
+	return config, true, nil
+}
+
+// size returns the requested size of the cache volume
+func (b buildCacheConfig) size() resource.Quantity {
+	if len(b.Size) == 0 {
+		return resource.MustParse(defaultBuildCacheSize)
+	}
+	return resource.MustParse(b.Size)
+}
+
+// accessMode returns the access mode of the cache volume: namespace caches are shared by the builds of all the components
+// of the namespace, which might run concurrently on different nodes
+func (b buildCacheConfig) accessMode() corev1.PersistentVolumeAccessMode {
+	if b.Scope == namespaceCacheScope {
+		return corev1.ReadWriteMany
+	}
+	return corev1.ReadWriteOnce
+}
+
+// cached returns whether the content of the Task volume with the specified name is kept by the cache and, if so, the
+// cache sub directory holding it
+func (b buildCacheConfig) cached(volume string) (string, bool) {
+	if b.Scope == namespaceCacheScope && containerStorageVolumes[volume] {
+		return "", false
+	}
+	subPath, ok := cachedVolumes[volume]
+	return subPath, ok
+}
+
+// name returns the name of the cache volume claim used by the specified component
+func (b buildCacheConfig) name(c *v1beta1.Component) string {
+	if b.Scope == namespaceCacheScope {
+		return namespaceBuildCacheName
+	}
+	return c.Name + "-build-cache"
+}
+
+// ensureBuildCache creates the cache volume claim used by the builds of the specified component if needed. The claim is
+// owned by the component, and thus deleted along with it, only for component caches using the delete cleanup policy.
+// Requesting a size different from the one of an existing claim is rejected since claims cannot be shrunk and most
+// storage classes cannot expand them.
+func ensureBuildCache(c *v1beta1.Component) error {
+	config, enabled, err := getBuildCacheConfig(c)
+	if err != nil || !enabled {
+		return err
+	}
+	name := config.name(c)
+	existing, err := framework.Helper.Fetch(name, c.Namespace, &corev1.PersistentVolumeClaim{})
+	if err == nil {
+		return checkBuildCacheSize(existing.(*corev1.PersistentVolumeClaim), config)
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: c.Namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{config.accessMode()},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: config.size(),
+				},
+			},
+		},
+	}
+	if len(config.StorageClass) > 0 {
+		pvc.Spec.StorageClassName = &config.StorageClass
+	}
+	if config.Scope == componentCacheScope {
+		pvc.Labels = getBuildLabels(c.Name)
+		if config.Cleanup == deleteCacheCleanup {
+			if err := controllerutil.SetControllerReference(c, pvc, framework.Helper.Scheme); err != nil {
+				return err
+			}
+		}
+	}
+	if err := framework.Helper.Client.Create(context.TODO(), pvc); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// checkBuildCacheSize returns an error if the specified build cache configuration explicitly requests a size different
+// from the one the specified existing claim was created with
+func checkBuildCacheSize(pvc *corev1.PersistentVolumeClaim, config buildCacheConfig) error {
+	if len(config.Size) == 0 {
+		return nil
+	}
+	requested := config.size()
+	if existing := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; existing.Cmp(requested) != 0 {
+		return fmt.Errorf("build cache '%s' was created with a size of %s and cannot be resized to %s, delete it to recreate it with the new size",
+			pvc.Name, existing.String(), requested.String())
+	}
+	return nil
+}
+
+// configure makes the specified Task keep the content of its cached volumes in the cache volume claim with the specified
+// name. The cache is wiped before building if its usage exceeds the configured threshold and its usage is reported once
+// the build is done.
+func (b buildCacheConfig) configure(spec *v1alpha1.TaskSpec, claimName string) {
+	volumes := make([]corev1.Volume, 0, len(spec.Volumes)+1)
+	subPaths := make([]string, 0, len(cachedVolumes))
+	for _, volume := range spec.Volumes {
+		if subPath, ok := b.cached(volume.Name); ok {
+			subPaths = append(subPaths, subPath)
+			continue
+		}
+		volumes = append(volumes, volume)
+	}
+	if len(subPaths) == 0 {
+		return
+	}
+	sort.Strings(subPaths)
+	spec.Volumes = append(volumes, corev1.Volume{
+		Name: buildCacheVolume,
+		VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: claimName,
+		}},
+	})
+	for i := range spec.Steps {
+		mounts := spec.Steps[i].VolumeMounts
+		for j := range mounts {
+			if subPath, ok := b.cached(mounts[j].Name); ok {
+				mounts[j].Name = buildCacheVolume
+				mounts[j].SubPath = subPath
+			}
+		}
+	}
+
+	cacheMount := []corev1.VolumeMount{{Name: buildCacheVolume, MountPath: buildCacheDir}}
+	dirs := buildCacheDir + "/" + strings.Join(subPaths, " "+buildCacheDir+"/")
+	prepare := v1alpha1.Step{Container: corev1.Container{
+		// Wipe the cache if it is getting full and make sure that builds, which might not run as root, can write to it
+		Name:    "prepare-cache",
+		Image:   "alpine",
+		Command: []string{"/bin/sh", "-c"},
+		Args: []string{fmt.Sprintf(`usage=$(df -P %[1]s | awk 'NR==2 {print $5}' | tr -d %%); `+
+			`if [ "$usage" -gt %[2]d ]; then echo "Cache usage is ${usage}%%, wiping it"; rm -rf %[3]s; fi; `+
+			`mkdir -p %[3]s && chmod 777 %[3]s`, buildCacheDir, b.MaxUsagePercent, dirs)},
+		VolumeMounts: cacheMount,
+	}}
+	report := v1alpha1.Step{Container: corev1.Container{
+		// Report the cache usage so that it can be displayed in the component status
+		Name:    "report-cache",
+		Image:   "alpine",
+		Command: []string{"/bin/sh", "-c"},
+		Args: []string{reportResultCommand(cacheUsageResult,
+			fmt.Sprintf(`"$(du -sh %[1]s | cut -f1) ($(df -P %[1]s | awk 'NR==2 {print $5}') of the volume)"`, buildCacheDir))},
+		TerminationMessagePath: stepResultsPath,
+		VolumeMounts:           cacheMount,
+	}}
+	spec.Steps = append(append([]v1alpha1.Step{prepare}, spec.Steps...), report)
+}
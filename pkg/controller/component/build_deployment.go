@@ -2,12 +2,12 @@ package component
 
 import (
 	component "halkyon.io/api/component/v1beta1"
-	"halkyon.io/operator-framework"
 	"k8s.io/api/apps/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"strings"
 )
 
 //createBuildDeployment returns the Deployment config object to be used for deployment using a container image build by Tekton
@@ -22,32 +22,27 @@ func (res deployment) installBuild(empty bool) (runtime.Object, error) {
 		if err != nil {
 			return nil, err
 		}
-		// We will check if a Dev Deployment exists.
-		// If this is the case, then that means that we are switching from dev to build mode
-		// and we will enrich the deployment resource of the runtime container
-		// create a "dev" version of the component to be able to check if the dev deployment exists
-		devDeployment := &appsv1.Deployment{}
-		_, err = framework.Helper.Fetch(c.DeploymentNameFor(component.DevDeploymentMode), c.Namespace, devDeployment)
-		if err == nil {
-			devContainer := &devDeployment.Spec.Template.Spec.Containers[0]
-			runtimeContainer.Env = devContainer.Env
-			runtimeContainer.EnvFrom = devContainer.EnvFrom
-			runtimeContainer.Env = updateEnv(runtimeContainer.Env, c.Annotations["app.openshift.io/java-app-jar"])
-			runtimeContainer.Ports = devContainer.Ports
-		} else {
-			runtimeContainer.Ports = []corev1.ContainerPort{{
-				ContainerPort: c.Spec.Port,
-				Name:          "http",
-				Protocol:      "TCP",
-			}}
+		// the deployment is derived from the component only so that switching modes yields the same env, links and ports
+		runtimeContainer.EnvFrom = linkedCapabilitiesEnvFrom(c)
+		runtimeContainer.Ports = containerPortsFor(c)
+		probes, err := getProbes(c)
+		if err != nil {
+			return nil, err
 		}
+		setProbes(&runtimeContainer, probes, c.Spec.Port)
 
 		dep.ObjectMeta = metav1.ObjectMeta{
 			Name:      res.Name(),
 			Namespace: c.Namespace,
 			Labels:    ls,
 		}
+		scaling, err := getScalingConfig(c)
+		if err != nil {
+			return nil, err
+		}
+
 		dep.Spec = v1.DeploymentSpec{
+			Replicas: buildReplicas(c, scaling, runtimeContainer.ImagePullPolicy, nil),
 			Strategy: v1.DeploymentStrategy{
 				Type: v1.RollingUpdateDeploymentStrategyType,
 			},
@@ -63,7 +58,6 @@ func (res deployment) installBuild(empty bool) (runtime.Object, error) {
 					Containers: []corev1.Container{runtimeContainer},
 				}},
 		}
-
 	}
 
 	// Set Component instance as the owner and controller
@@ -75,23 +69,55 @@ func getRuntimeContainerFor(component *component.Component) (corev1.Container, e
 	if err != nil {
 		return corev1.Container{}, err
 	}
+	runtimeImage, err := getImageInfo(component)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	resources, err := getResourceRequirements(component, runtimeImage)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	image, pullPolicy := builtImage(component, "")
 	container := corev1.Container{
 		Env:             env,
-		Image:           dockerImageURL(component),
-		ImagePullPolicy: corev1.PullAlways,
+		Image:           image,
+		ImagePullPolicy: pullPolicy,
 		Name:            component.Name,
+		Resources:       resources,
 	}
 	return container, nil
 }
 
-func updateEnv(envs []corev1.EnvVar, jarName string) []corev1.EnvVar {
-	newEnvs := []corev1.EnvVar{}
-	for _, s := range envs {
-		if s.Name == "JAVA_APP_JAR" {
-			newEnvs = append(newEnvs, corev1.EnvVar{Name: s.Name, Value: jarName})
-		} else {
-			newEnvs = append(newEnvs, s)
+// builtImage returns the image the build deployment should run along with its pull policy. Once the current build
+// succeeded, the image is referenced by the digest it reported so that pods run exactly what was built. Until then, the
+// currently deployed image is kept if it is already pinned, the mutable tag only being used when no digest is known.
+func builtImage(c *component.Component, current string) (string, corev1.PullPolicy) {
+	if run, err := fetchBuildRun(c); err == nil && run.isSucceeded() {
+		if digest := builtImageDigest(run); len(digest) > 0 {
+			return pinnedImage(c, digest), corev1.PullIfNotPresent
 		}
 	}
-	return newEnvs
+	if strings.Contains(current, "@") {
+		return current, corev1.PullIfNotPresent
+	}
+	return dockerImageURL(c), corev1.PullAlways
+}
+
+// buildReplicas returns the replicas the build deployment should specify given the pull policy of its image. Components
+// built by a pipeline don't run until an image passed all its stages, i.e. until the image is pinned, a replica being
+// started then if the number of replicas isn't managed by the operator.
+func buildReplicas(c *component.Component, scaling scalingConfig, pullPolicy corev1.PullPolicy, current *int32) *int32 {
+	replicas := scaling.desiredReplicas()
+	if !usesPipeline(c) {
+		return replicas
+	}
+	if pullPolicy == corev1.PullAlways {
+		none := int32(0)
+		return &none
+	}
+	if replicas == nil && current != nil && *current == 0 {
+		one := int32(1)
+		return &one
+	}
+	return replicas
 }
@@ -0,0 +1,128 @@
+package component
+
+import (
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator-framework/util"
+	corev1 "k8s.io/api/core/v1"
+	"path"
+	"strings"
+)
+
+// buildahImage is the image running the buildah steps
+const buildahImage = "quay.io/buildah/stable:v1.14.0"
+
+// dockerfileStrategy builds and pushes the image using buildah and a Dockerfile provided with the sources
+type dockerfileStrategy struct{}
+
+func init() {
+	registerBuildStrategy("dockerfile", dockerfileStrategy{})
+}
+
+func (dockerfileStrategy) taskName() string {
+	return "dockerfile-buildah-push"
+}
+
+func (dockerfileStrategy) taskSpec() v1alpha1.TaskSpec {
+	spec := buildTaskSpec(
+		stringParamSpec("dockerfile", "Dockerfile", "The path of the Dockerfile to use, relative to the context path"),
+	)
+	spec.Steps = buildahSteps("$(inputs.params.workspacePath)/$(inputs.params.contextPath)/$(inputs.params.dockerfile)", "$(inputs.params.workspacePath)/$(inputs.params.contextPath)")
+	spec.Volumes = buildahVolumes()
+	return spec
+}
+
+func (dockerfileStrategy) taskRunParams(c *v1beta1.Component) []v1alpha1.Param {
+	return []v1alpha1.Param{
+		stringParam("contextPath", contextPath(c)),
+		stringParam("dockerfile", dockerfilePath(c)),
+	}
+}
+
+// defaultDockerfile is the Dockerfile built when the component doesn't specify one
+const defaultDockerfile = "Dockerfile"
+
+// getDockerfile returns the path, relative to the context path, of the Dockerfile the specified component is built with
+func getDockerfile(c *v1beta1.Component) (string, error) {
+	dockerfile := defaultDockerfile
+	if _, err := unmarshalAnnotation(c, DockerfileAnnotation, &dockerfile); err != nil {
+		return defaultDockerfile, err
+	}
+	if cleaned := path.Clean(dockerfile); len(dockerfile) == 0 || path.IsAbs(dockerfile) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return defaultDockerfile, fmt.Errorf("'%s' Dockerfile in '%s' annotation must be a path relative to, and within, the context path", dockerfile, DockerfileAnnotation)
+	}
+	return dockerfile, nil
+}
+
+// dockerfilePath returns the path of the Dockerfile the specified component is built with, invalid paths being reported
+// when checking the component's validity
+func dockerfilePath(c *v1beta1.Component) string {
+	dockerfile, _ := getDockerfile(c)
+	return dockerfile
+}
+
+// buildahBuildStep is the name of the step building the image in the steps returned by buildahSteps
+const buildahBuildStep = "build"
+
+// buildahSteps returns the steps building the image using the specified Dockerfile and context directory, then pushing it
+func buildahSteps(dockerfile, contextDir string, extraMounts ...corev1.VolumeMount) []v1alpha1.Step {
+	return []v1alpha1.Step{
+		{Container: corev1.Container{
+			// Build a Container image using the dockerfile
+			Name:       buildahBuildStep,
+			Image:      buildahImage,
+			WorkingDir: contextDir,
+			Command: []string{
+				"buildah",
+			},
+			Args: []string{
+				"bud",
+				"--tls-verify=$(inputs.params.verifyTLS)",
+				"--layers",
+				"-f",
+				dockerfile,
+				"-t",
+				"$(outputs.resources.image.url)",
+				"."},
+			VolumeMounts: append([]corev1.VolumeMount{
+				{
+					Name:      "libcontainers",
+					MountPath: "/var/lib/containers",
+				},
+			}, extraMounts...),
+			SecurityContext: &corev1.SecurityContext{
+				Privileged: util.NewTrue(),
+			},
+		}},
+		{Container: corev1.Container{
+			// Push the image created to quay.io using as credentials the secret mounted within
+			// the service account
+			// The digest of the pushed image is reported as result so that deployments can reference it
+			Name:    "push",
+			Image:   buildahImage,
+			Command: []string{"/bin/sh", "-c"},
+			Env: []corev1.EnvVar{
+				{Name: "REGISTRY_AUTH_FILE", Value: "/home/builder/.docker/config.json"},
+			},
+			Args: []string{
+				"buildah push --tls-verify=$(inputs.params.verifyTLS) --digestfile " + digestFile + " " +
+					"$(outputs.resources.image.url) docker://$(outputs.resources.image.url) && " + reportDigestCommand,
+			},
+			TerminationMessagePath: stepResultsPath,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					MountPath: "/var/lib/containers",
+					Name:      "libcontainers"},
+			},
+			SecurityContext: &corev1.SecurityContext{
+				Privileged: util.NewTrue(),
+			},
+		}},
+	}
+}
+
+// buildahVolumes returns the volumes needed by the buildah steps along with the specified extra volumes
+func buildahVolumes(extra ...corev1.Volume) []corev1.Volume {
+	return append(extra, corev1.Volume{Name: "libcontainers", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}})
+}
@@ -0,0 +1,62 @@
+package component
+
+import (
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// jibStrategy builds and pushes the image of a Maven project using Jib, without needing a Dockerfile or container runtime
+type jibStrategy struct{}
+
+func init() {
+	registerBuildStrategy("jib", jibStrategy{})
+}
+
+func (jibStrategy) taskName() string {
+	return "jib-maven-push"
+}
+
+func (jibStrategy) taskSpec() v1alpha1.TaskSpec {
+	spec := buildTaskSpec(
+		stringParamSpec("mavenImage", "maven:3.6-jdk-11", "The Maven image to run Jib with"),
+		stringParamSpec("moduleDirName", ".", "The name of the directory containing the Maven module to be built"),
+	)
+	spec.Steps = []v1alpha1.Step{
+		{Container: corev1.Container{
+			// Compile the project then build and push its image using the Jib Maven plugin
+			Name:       "build",
+			Image:      "$(inputs.params.mavenImage)",
+			WorkingDir: "$(inputs.params.workspacePath)/$(inputs.params.contextPath)",
+			Command:    []string{"/bin/sh", "-c"},
+			Args: []string{
+				`if [ "$VERIFY_TLS" = "true" ]; then INSECURE=false; else INSECURE=true; fi; ` +
+					`mvn -B -pl "$MODULE" -Dmaven.repo.local=` + mavenRepositoryPath + ` compile com.google.cloud.tools:jib-maven-plugin:2.1.0:build ` +
+					`-Dimage="$IMAGE" -Djib.allowInsecureRegistries=$INSECURE -Djib.outputPaths.digest=` + digestFile + ` && ` +
+					reportDigestCommand,
+			},
+			TerminationMessagePath: stepResultsPath,
+			Env: []corev1.EnvVar{
+				{Name: "VERIFY_TLS", Value: "$(inputs.params.verifyTLS)"},
+				{Name: "MODULE", Value: "$(inputs.params.moduleDirName)"},
+				{Name: "IMAGE", Value: "$(outputs.resources.image.url)"},
+				{Name: "DOCKER_CONFIG", Value: "/home/builder/.docker"},
+			},
+			VolumeMounts: []corev1.VolumeMount{mavenRepositoryMount},
+		}},
+	}
+	spec.Volumes = []corev1.Volume{mavenRepositoryVolumeFor()}
+	return spec
+}
+
+func (jibStrategy) taskRunParams(c *v1beta1.Component) []v1alpha1.Param {
+	params := []v1alpha1.Param{
+		stringParam("moduleDirName", moduleDirName(c)),
+		stringParam("contextPath", contextPath(c)),
+	}
+	// the base image is interpreted as the Maven image to build with for Jib
+	if len(c.Spec.BuildConfig.BaseImage) > 0 {
+		params = append(params, stringParam("mavenImage", c.Spec.BuildConfig.BaseImage))
+	}
+	return params
+}
@@ -0,0 +1,244 @@
+package component
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+)
+
+// BuildExecutorEnvVar selects what runs builds: "tekton" or "job", Tekton being used if it is installed when not set
+const BuildExecutorEnvVar = "BUILD_EXECUTOR"
+
+const (
+	tektonExecutor = "tekton"
+	jobExecutor    = "job"
+	// gitImage is the image cloning the sources of builds run as Jobs
+	gitImage = "alpine/git:v2.26.2"
+	// jobBuildTimeout is the maximum duration of builds run as Jobs, matching the default TaskRun timeout
+	jobBuildTimeout   = int64(3600)
+	gitCredentialsDir = "/etc/git-credentials"
+	// workspaceDir and homeDir are where Tekton mounts the workspace and the home directory shared by the steps
+	workspaceDir = "/workspace"
+	homeDir      = "/tekton/home"
+)
+
+// cloneScript clones the sources the way Tekton's git PipelineResource does, using the credentials of the git secret if
+// one is mounted
+const cloneScript = `set -e
+if [ -f ` + gitCredentialsDir + `/ssh-privatekey ]; then
+  mkdir -p "$HOME/.ssh" && cp ` + gitCredentialsDir + `/ssh-privatekey "$HOME/.ssh/id_git" && chmod 600 "$HOME/.ssh/id_git"
+  export GIT_SSH_COMMAND="ssh -i $HOME/.ssh/id_git -o StrictHostKeyChecking=no"
+elif [ -f ` + gitCredentialsDir + `/username ]; then
+  git config --global credential.helper '!f() { echo "username=$(cat ` + gitCredentialsDir + `/username)"; echo "password=$(cat ` + gitCredentialsDir + `/password)"; }; f'
+fi
+if [ "$GIT_DEPTH" != "0" ]; then DEPTH="--depth=$GIT_DEPTH"; fi
+git init "$GIT_PATH" && cd "$GIT_PATH"
+git remote add origin "$GIT_URL"
+git fetch $DEPTH origin "$GIT_REVISION"
+git checkout FETCH_HEAD
+if [ "$GIT_SUBMODULES" = "true" ]; then git submodule update --init --recursive $DEPTH; fi`
+
+// buildExecutor returns what runs builds, as configured for the operator or depending on whether Tekton is installed
+func buildExecutor() (string, error) {
+	executor := strings.ToLower(os.Getenv(BuildExecutorEnvVar))
+	switch executor {
+	case "":
+		if isTektonAvailable() {
+			return tektonExecutor, nil
+		}
+		return jobExecutor, nil
+	case tektonExecutor:
+		if !isTektonAvailable() {
+			return executor, fmt.Errorf("'%s' is set to %s but Tekton isn't installed", BuildExecutorEnvVar, tektonExecutor)
+		}
+		return executor, nil
+	case jobExecutor:
+		return executor, nil
+	default:
+		return executor, fmt.Errorf("invalid '%s' value %s, must be either %s or %s", BuildExecutorEnvVar, executor, tektonExecutor, jobExecutor)
+	}
+}
+
+// usesJobExecutor checks whether builds run as Jobs, invalid configurations being reported when validating components
+func usesJobExecutor() bool {
+	executor, err := buildExecutor()
+	return err == nil && executor == jobExecutor
+}
+
+// buildJob returns a Job running the steps of the specified Task with the given parameters, the same way Tekton would:
+// the sources are cloned by a first init container and all steps but the last one run as init containers so that they
+// run in sequence, sharing the workspace and home directory
+func buildJob(c *v1beta1.Component, meta metav1.ObjectMeta, spec v1alpha1.TaskSpec, params []v1alpha1.Param, git gitConfig) *batchv1.Job {
+	values := make(map[string]string, len(params)+5)
+	if spec.Inputs != nil {
+		for _, param := range spec.Inputs.Params {
+			if param.Default != nil {
+				values[param.Name] = param.Default.StringVal
+			}
+		}
+	}
+	for _, param := range params {
+		values[param.Name] = param.Value.StringVal
+	}
+	replacements := []string{
+		"$(outputs.resources.image.url)", dockerImageURL(c),
+		"$(inputs.resources.git.path)", sourceWorkspacePath,
+	}
+	for name, value := range values {
+		replacements = append(replacements, "$(inputs.params."+name+")", value)
+	}
+	variables := strings.NewReplacer(replacements...)
+
+	shared := []corev1.VolumeMount{
+		{Name: "workspace", MountPath: workspaceDir},
+		{Name: "home", MountPath: homeDir},
+	}
+	volumes := append([]corev1.Volume{
+		{Name: "workspace", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		{Name: "home", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	}, spec.Volumes...)
+
+	depth, submodules := 1, true
+	if git.Depth != nil {
+		depth = *git.Depth
+	}
+	if git.Submodules != nil {
+		submodules = *git.Submodules
+	}
+	clone := corev1.Container{
+		Name:    "step-clone",
+		Image:   gitImage,
+		Command: []string{"/bin/sh", "-c"},
+		Args:    []string{cloneScript},
+		Env: []corev1.EnvVar{
+			{Name: "GIT_URL", Value: c.Spec.BuildConfig.URL},
+			{Name: "GIT_REVISION", Value: GitRevision(c)},
+			{Name: "GIT_PATH", Value: sourceWorkspacePath},
+			{Name: "GIT_DEPTH", Value: strconv.Itoa(depth)},
+			{Name: "GIT_SUBMODULES", Value: strconv.FormatBool(submodules)},
+			{Name: "HOME", Value: homeDir},
+		},
+		VolumeMounts: shared,
+	}
+	if len(git.Secret) > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name:         "git-credentials",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: git.Secret}},
+		})
+		clone.VolumeMounts = append(clone.VolumeMounts, corev1.VolumeMount{Name: "git-credentials", MountPath: gitCredentialsDir, ReadOnly: true})
+	}
+
+	containers := []corev1.Container{clone}
+	for _, step := range spec.Steps {
+		container := step.Container.DeepCopy()
+		container.Name = "step-" + step.Name
+		replaceVariables(container, variables)
+		container.VolumeMounts = append(container.VolumeMounts, shared...)
+		setEnvIfMissing(container, "HOME", homeDir)
+		containers = append(containers, *container)
+	}
+
+	timeout := jobBuildTimeout
+	noRetry := int32(0)
+	return &batchv1.Job{
+		ObjectMeta: meta,
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &noRetry,
+			ActiveDeadlineSeconds: &timeout,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: meta.Labels},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: ServiceAccountName(c),
+					RestartPolicy:      corev1.RestartPolicyNever,
+					InitContainers:     containers[:len(containers)-1],
+					Containers:         containers[len(containers)-1:],
+					Volumes:            volumes,
+				},
+			},
+		},
+	}
+}
+
+// asJobBuildRun returns a view of the specified build Job. The state of its steps and the results they reported are held
+// by the Job's pod and thus only known once retrieved by fetchJobPod.
+func asJobBuildRun(job *batchv1.Job) *buildRun {
+	run := &buildRun{Object: job, object: job, results: map[string]string{}}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		transition := apis.VolatileTime{Inner: condition.LastTransitionTime}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			run.succeeded = &apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue, LastTransitionTime: transition, Reason: condition.Reason, Message: condition.Message}
+		case batchv1.JobFailed:
+			run.succeeded = &apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, LastTransitionTime: transition, Reason: condition.Reason, Message: condition.Message}
+		}
+	}
+	return run
+}
+
+// fetchJobPod retrieves, using the cached client, the pod of the specified build if it is a Job and records the state of
+// its steps and the results they reported. Tekton builds already hold them in their status and are left untouched.
+func fetchJobPod(run *buildRun) error {
+	job, ok := run.object.(*batchv1.Job)
+	if !ok {
+		return nil
+	}
+	pods := &corev1.PodList{}
+	lo := &client.ListOptions{}
+	lo.InNamespace(job.Namespace)
+	lo.MatchingLabels(map[string]string{"job-name": job.Name})
+	if err := framework.Helper.Client.List(context.TODO(), lo, pods); err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return nil
+	}
+	// only consider the latest pod, should the Job have been retried
+	pod := &pods.Items[0]
+	for i := range pods.Items {
+		if pod.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) {
+			pod = &pods.Items[i]
+		}
+	}
+	setJobPod(run, pod)
+	return nil
+}
+
+// setJobPod records the state of the steps of the specified build Job run by the given pod and the results they reported
+func setJobPod(run *buildRun, pod *corev1.Pod) {
+	run.podName = pod.Name
+	run.steps = nil
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		run.steps = append(run.steps, v1alpha1.StepState{
+			ContainerState: status.State,
+			Name:           strings.TrimPrefix(status.Name, "step-"),
+			ContainerName:  status.Name,
+		})
+		if terminated := status.State.Terminated; terminated != nil && len(terminated.Message) > 0 {
+			// steps report their results in their termination message as Tekton expects them with the v1alpha1 API
+			var results []struct {
+				Key   string `json:"key"`
+				Value string `json:"value"`
+			}
+			if err := json.Unmarshal([]byte(terminated.Message), &results); err == nil {
+				for _, result := range results {
+					run.results[result.Key] = strings.TrimSpace(result.Value)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package component
+
+import (
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildExecutor(t *testing.T) {
+	defer os.Unsetenv(BuildExecutorEnvVar)
+	cases := []struct {
+		env      string
+		tekton   bool
+		jobs     bool
+		executor string
+		valid    bool
+	}{
+		{env: "", tekton: true, executor: tektonExecutor, valid: true},
+		{env: "", tekton: false, executor: jobExecutor, jobs: true, valid: true},
+		{env: "tekton", tekton: true, executor: tektonExecutor, valid: true},
+		{env: "Tekton", tekton: false, executor: tektonExecutor, valid: false},
+		{env: "job", tekton: true, executor: jobExecutor, jobs: true, valid: true},
+		{env: "job", tekton: false, executor: jobExecutor, jobs: true, valid: true},
+		{env: "pod", tekton: true, executor: "pod", valid: false},
+	}
+	for _, c := range cases {
+		setTektonAvailable(c.tekton)
+		if err := os.Setenv(BuildExecutorEnvVar, c.env); err != nil {
+			t.Fatal(err)
+		}
+		executor, err := buildExecutor()
+		if c.valid != (err == nil) {
+			t.Errorf("expected '%s' executor validity with Tekton available %t to be %t, got error: %v", c.env, c.tekton, c.valid, err)
+		}
+		if executor != c.executor {
+			t.Errorf("expected '%s' executor with Tekton available %t to be %s, got %s", c.env, c.tekton, c.executor, executor)
+		}
+		if jobs := usesJobExecutor(); jobs != c.jobs {
+			t.Errorf("expected '%s' executor with Tekton available %t to run builds as Jobs: %t, got %t", c.env, c.tekton, c.jobs, jobs)
+		}
+	}
+}
+
+func TestBuildJob(t *testing.T) {
+	defer os.Unsetenv(RegistryAddressEnvVar)
+	if err := os.Setenv(RegistryAddressEnvVar, "registry.local"); err != nil {
+		t.Fatal(err)
+	}
+	c := &v1beta1.Component{ObjectMeta: metav1.ObjectMeta{Name: "fruits", Namespace: "demo"}}
+	c.Spec.BuildConfig.URL = "https://github.com/halkyonio/operator"
+	c.Spec.BuildConfig.Ref = "v1.0.0"
+	meta := metav1.ObjectMeta{Name: "fruits-build", Namespace: "demo", Labels: map[string]string{"component": "fruits"}}
+	spec := v1alpha1.TaskSpec{
+		Inputs: &v1alpha1.Inputs{
+			Params: []v1alpha1.ParamSpec{
+				stringParamSpec("contextPath", ".", "The context path"),
+				stringParamSpec("verifyTLS", "true", "Verify registry certificates"),
+			},
+		},
+		Steps: []v1alpha1.Step{
+			{Container: corev1.Container{
+				Name:       "build",
+				Image:      "quay.io/buildah/stable",
+				WorkingDir: "$(inputs.resources.git.path)/$(inputs.params.contextPath)",
+				Args:       []string{"bud", "--tls-verify=$(inputs.params.verifyTLS)", "-t", "$(outputs.resources.image.url)"},
+			}},
+			{Container: corev1.Container{
+				Name:  "push",
+				Image: "quay.io/buildah/stable",
+				Env:   []corev1.EnvVar{{Name: "HOME", Value: "/root"}},
+			}},
+		},
+		Volumes: []corev1.Volume{{Name: "libcontainers", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+	}
+	depth := 0
+	job := buildJob(c, meta, spec, []v1alpha1.Param{stringParam("contextPath", "fruits")}, gitConfig{Secret: "git-credentials", Depth: &depth})
+
+	if job.Name != meta.Name || !reflect.DeepEqual(job.Spec.Template.Labels, meta.Labels) {
+		t.Errorf("expected Job and its pods to use %+v metadata, got %+v and %v pod labels", meta, job.ObjectMeta, job.Spec.Template.Labels)
+	}
+	if job.Spec.BackoffLimit == nil || *job.Spec.BackoffLimit != 0 {
+		t.Errorf("expected failed builds not to be retried, got %v backoff limit", job.Spec.BackoffLimit)
+	}
+	pod := job.Spec.Template.Spec
+	if pod.RestartPolicy != corev1.RestartPolicyNever || pod.ServiceAccountName != ServiceAccountName(c) {
+		t.Errorf("expected build pod to never restart and use the build service account, got %s and %s", pod.RestartPolicy, pod.ServiceAccountName)
+	}
+
+	// steps run in sequence: the clone and all steps but the last one as init containers
+	names := make([]string, 0, 3)
+	for _, container := range pod.InitContainers {
+		names = append(names, container.Name)
+	}
+	if expected := []string{"step-clone", "step-build"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v init containers, got %v", expected, names)
+	}
+	if len(pod.Containers) != 1 || pod.Containers[0].Name != "step-push" {
+		t.Fatalf("expected the last step to run as the pod container, got %+v", pod.Containers)
+	}
+
+	clone := pod.InitContainers[0]
+	env := make(map[string]string, len(clone.Env))
+	for _, e := range clone.Env {
+		env[e.Name] = e.Value
+	}
+	if env["GIT_URL"] != c.Spec.BuildConfig.URL || env["GIT_REVISION"] != "v1.0.0" || env["GIT_DEPTH"] != "0" || env["GIT_SUBMODULES"] != "true" {
+		t.Errorf("unexpected clone environment: %v", env)
+	}
+	if !hasVolumeMount(clone, "git-credentials") || !hasVolume(pod, "git-credentials") {
+		t.Errorf("expected the git secret to be mounted by the clone step")
+	}
+
+	build := pod.InitContainers[1]
+	if build.WorkingDir != sourceWorkspacePath+"/fruits" {
+		t.Errorf("expected specified param to replace the default, got '%s' working dir", build.WorkingDir)
+	}
+	if expected := []string{"bud", "--tls-verify=true", "-t", "registry.local/demo/fruits"}; !reflect.DeepEqual(build.Args, expected) {
+		t.Errorf("expected %v build args, got %v", expected, build.Args)
+	}
+	for _, container := range append(pod.InitContainers, pod.Containers...) {
+		if !hasVolumeMount(container, "workspace") || !hasVolumeMount(container, "home") {
+			t.Errorf("expected '%s' step to share the workspace and home directory", container.Name)
+		}
+	}
+	if home := envValue(build, "HOME"); home != homeDir {
+		t.Errorf("expected HOME to default to %s, got '%s'", homeDir, home)
+	}
+	if home := envValue(pod.Containers[0], "HOME"); home != "/root" {
+		t.Errorf("expected step HOME to be kept, got '%s'", home)
+	}
+	if !hasVolume(pod, "libcontainers") {
+		t.Errorf("expected Task volumes to be kept")
+	}
+}
+
+func TestSetJobPod(t *testing.T) {
+	job := asJobBuildRun(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "fruits-build"}})
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "fruits-build-x2k4p"},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{Name: "step-clone", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "step-push", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Message: `[{"key":"digest","value":"sha256:1234\n"}]`,
+				}}},
+			},
+		},
+	}
+	setJobPod(job, pod)
+	if job.podName != pod.Name {
+		t.Errorf("expected '%s' pod, got '%s'", pod.Name, job.podName)
+	}
+	if len(job.steps) != 2 || job.steps[0].Name != "clone" || job.steps[1].Name != "push" || job.steps[1].ContainerName != "step-push" {
+		t.Errorf("expected clone and push steps, got %+v", job.steps)
+	}
+	if digest := job.results[digestResult]; digest != "sha256:1234" {
+		t.Errorf("expected reported digest, got '%s'", digest)
+	}
+}
+
+func TestAsJobBuildRun(t *testing.T) {
+	completed := metav1.Date(2020, 6, 1, 12, 30, 0, 0, time.UTC)
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "fruits-build"}}
+	job.Status.Conditions = []batchv1.JobCondition{
+		{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: completed, Reason: "Completed"},
+	}
+	run := asJobBuildRun(job)
+	if !run.isSucceeded() {
+		t.Fatalf("expected completed Job to be a succeeded build, got %+v", run.succeeded)
+	}
+	if builtAt := run.succeeded.LastTransitionTime.Inner; !builtAt.Equal(&completed) {
+		t.Errorf("expected build to complete when the Job did, got %v", builtAt)
+	}
+
+	job.Status.Conditions[0].Type = batchv1.JobFailed
+	if run := asJobBuildRun(job); run.succeeded == nil || run.succeeded.Status != corev1.ConditionFalse || !run.succeeded.LastTransitionTime.Inner.Equal(&completed) {
+		t.Errorf("expected failed Job to be a failed build with its failure time, got %+v", run.succeeded)
+	}
+	if run := asJobBuildRun(&batchv1.Job{}); run.succeeded != nil {
+		t.Errorf("expected running Job not to be done, got %+v", run.succeeded)
+	}
+}
@@ -0,0 +1,150 @@
+package component
+
+import (
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"strings"
+	"sync"
+)
+
+const (
+	// buildLogTailLines is the number of log lines retrieved from a failed build step
+	buildLogTailLines = int64(20)
+	// maxBuildLogLength is the maximum length of the log excerpt recorded in the component status
+	maxBuildLogLength = 2048
+)
+
+var podsClient struct {
+	sync.Once
+	client corev1client.CoreV1Interface
+}
+
+// InitClients creates the clients the component controller uses besides the manager's one. It is meant to be called at
+// startup, subsequent calls having no effect.
+func InitClients(config *rest.Config) {
+	podsClient.Do(func() {
+		podsClient.client = kubernetes.NewForConfigOrDie(config).CoreV1()
+	})
+}
+
+// getPodsClient returns the client used to access build pods and their logs
+func getPodsClient() corev1client.CoreV1Interface {
+	InitClients(framework.Helper.Config)
+	return podsClient.client
+}
+
+// buildFailure describes the step that made a build fail along with the tail of its log
+type buildFailure struct {
+	// owner identifies the component the failed build belongs to as namespace/name
+	owner    string
+	step     string
+	exitCode int32
+	log      string
+}
+
+// buildFailures caches the failures of builds by UID so that logs are only retrieved once, when the build fails
+var buildFailures = struct {
+	sync.Mutex
+	byTaskRun map[string]*buildFailure
+}{byTaskRun: make(map[string]*buildFailure)}
+
+// getBuildFailure identifies the step that made the specified failed build of the given component fail and retrieves the
+// end of its log, returning nil if no step terminated in error. The log is retrieved without holding the cache lock and
+// only cached once retrieved, an error retrieving it being reported in its place until a later attempt succeeds.
+func getBuildFailure(c *v1beta1.Component, run *buildRun) *buildFailure {
+	key := string(run.GetUID())
+	buildFailures.Lock()
+	failure, ok := buildFailures.byTaskRun[key]
+	buildFailures.Unlock()
+	if ok {
+		return failure
+	}
+
+	step := failedStep(run)
+	if step == nil {
+		return nil
+	}
+	failure = &buildFailure{owner: ownerKey(c), step: step.Name, exitCode: step.Terminated.ExitCode}
+	containerName := step.ContainerName
+	if len(containerName) == 0 {
+		containerName = "step-" + step.Name
+	}
+	log, err := stepLog(run.GetNamespace(), run.podName, containerName)
+	if err != nil {
+		failure.log = fmt.Sprintf("couldn't retrieve log: %v", err)
+		return failure
+	}
+	failure.log = log
+	buildFailures.Lock()
+	buildFailures.byTaskRun[key] = failure
+	buildFailures.Unlock()
+	return failure
+}
+
+// ownerKey identifies the specified component in the build failures cache
+func ownerKey(c *v1beta1.Component) string {
+	return c.Namespace + "/" + c.Name
+}
+
+// failedStep returns the first step of the specified build that terminated with a non-zero exit code, if any
+func failedStep(run *buildRun) *v1alpha1.StepState {
+	for i := range run.steps {
+		step := &run.steps[i]
+		if step.Terminated != nil && step.Terminated.ExitCode != 0 {
+			return step
+		}
+	}
+	return nil
+}
+
+// stepLog retrieves the end of the log of the specified build pod container, trimmed to at most maxBuildLogLength
+func stepLog(namespace, podName, containerName string) (string, error) {
+	if len(podName) == 0 {
+		return "", fmt.Errorf("no pod is associated with the build")
+	}
+	tailLines := buildLogTailLines
+	raw, err := getPodsClient().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{
+		Container: containerName,
+		TailLines: &tailLines,
+	}).Do().Raw()
+	if err != nil {
+		return "", err
+	}
+	return trimLog(string(raw)), nil
+}
+
+// trimLog keeps the end of the specified log so that it fits in maxBuildLogLength, starting on a new line if possible
+func trimLog(log string) string {
+	log = strings.TrimSpace(log)
+	if len(log) <= maxBuildLogLength {
+		return log
+	}
+	log = log[len(log)-maxBuildLogLength:]
+	if newLine := strings.IndexRune(log, '\n'); newLine >= 0 && newLine < len(log)-1 {
+		log = log[newLine+1:]
+	}
+	return log
+}
+
+// forgetBuildFailures removes the cached failures of the builds of the specified component which aren't among the given
+// existing builds, all of them if none is specified
+func forgetBuildFailures(c *v1beta1.Component, existing ...*buildRun) {
+	kept := make(map[string]bool, len(existing))
+	for _, run := range existing {
+		kept[string(run.GetUID())] = true
+	}
+	owner := ownerKey(c)
+	buildFailures.Lock()
+	defer buildFailures.Unlock()
+	for key, failure := range buildFailures.byTaskRun {
+		if failure.owner == owner && !kept[key] {
+			delete(buildFailures.byTaskRun, key)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+)
+
+// RootlessBuildsEnvVar makes all builds rootless when set to "true", unless components specify otherwise
+const RootlessBuildsEnvVar = "ROOTLESS_BUILDS"
+
+// rootlessBuildUser is the non-root user of the buildah image rootless builds run as when the cluster doesn't assign one
+const rootlessBuildUser = int64(1000)
+
+// rootlessStorageVolume is the name given to the buildah storage volume in rootless mode so that, the storage drivers
+// being different, it is cached separately from the privileged one
+const rootlessStorageVolume = "libcontainers-vfs"
+
+// isRootlessBuild checks whether the specified component should be built without privileges, the value specified on
+// the component overriding the operator-wide one
+func isRootlessBuild(c *v1beta1.Component) (bool, error) {
+	rootless := false
+	if value, ok := os.LookupEnv(RootlessBuildsEnvVar); ok && len(value) > 0 {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid '%s' value: %s", RootlessBuildsEnvVar, err.Error())
+		}
+		rootless = parsed
+	}
+	if _, err := unmarshalAnnotation(c, RootlessBuildAnnotation, &rootless); err != nil {
+		return false, err
+	}
+	return rootless, nil
+}
+
+// makeRootless makes the buildah steps of the specified Task run without privileges, as a non-root user, using the vfs
+// storage driver and chroot isolation. The user is only set if assignUser is true since OpenShift assigns pods a user
+// from the namespace range, rejecting any other one.
+func makeRootless(spec *v1alpha1.TaskSpec, assignUser bool) {
+	nonRoot := true
+	for i := range spec.Steps {
+		step := &spec.Steps[i]
+		if step.Image != buildahImage {
+			continue
+		}
+		step.SecurityContext = &corev1.SecurityContext{RunAsNonRoot: &nonRoot}
+		if assignUser {
+			user := rootlessBuildUser
+			step.SecurityContext.RunAsUser = &user
+		}
+		setEnvIfMissing(&step.Container, "STORAGE_DRIVER", "vfs")
+		setEnvIfMissing(&step.Container, "BUILDAH_ISOLATION", "chroot")
+		for j := range step.VolumeMounts {
+			if step.VolumeMounts[j].Name == "libcontainers" {
+				step.VolumeMounts[j].Name = rootlessStorageVolume
+			}
+		}
+	}
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name == "libcontainers" {
+			spec.Volumes[i].Name = rootlessStorageVolume
+		}
+	}
+}
+
+// Names of the service accounts, roles and role bindings used by privileged and rootless builds, which are shared by the
+// components of a namespace. Rootless builds use their own service account so that they aren't granted the privileged SCC.
+const (
+	privilegedBuildServiceAccount = "build-bot"
+	privilegedBuildRole           = "image-scc-privileged-role"
+	privilegedBuildRoleBinding    = "use-image-scc-privileged"
+	rootlessBuildServiceAccount   = "build-bot-rootless"
+	rootlessBuildRole             = "image-builder-role"
+	rootlessBuildRoleBinding      = "use-image-builder"
+)
+
+// needsPrivilegedBuilds returns whether the specified component is built with privileges
+func needsPrivilegedBuilds(c *v1beta1.Component) bool {
+	// configuration errors are reported when checking the component's validity
+	rootless, _ := isRootlessBuild(c)
+	return v1beta1.BuildDeploymentMode == c.Spec.DeploymentMode && !rootless
+}
+
+// removeUnusedPrivilegedRole deletes the role binding, and role, granting the privileged SCC to the build service account
+// of the namespace of the specified component once none of its components is built with privileges anymore. Only the
+// role and binding created by the operator for a component are deleted.
+func removeUnusedPrivilegedRole(c *v1beta1.Component) error {
+	if needsPrivilegedBuilds(c) {
+		return nil
+	}
+	lo := &client.ListOptions{}
+	lo.InNamespace(c.Namespace)
+	components := &v1beta1.ComponentList{}
+	if err := framework.Helper.Client.List(context.TODO(), lo, components); err != nil {
+		return err
+	}
+	for i := range components.Items {
+		if needsPrivilegedBuilds(&components.Items[i]) {
+			return nil
+		}
+	}
+	unused := map[string]runtime.Object{
+		privilegedBuildRoleBinding: &rbacv1.RoleBinding{},
+		privilegedBuildRole:        &rbacv1.Role{},
+	}
+	for _, name := range []string{privilegedBuildRoleBinding, privilegedBuildRole} {
+		object := unused[name]
+		if _, err := framework.Helper.Fetch(name, c.Namespace, object); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !isControlledByComponent(object.(metav1.Object)) {
+			continue
+		}
+		if err := framework.Helper.Client.Delete(context.TODO(), object); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("couldn't remove unused '%s': %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// isControlledByComponent returns whether the specified object was created by the operator for a component
+func isControlledByComponent(object metav1.Object) bool {
+	owner := metav1.GetControllerOf(object)
+	return owner != nil && owner.Kind == "Component"
+}
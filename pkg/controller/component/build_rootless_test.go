@@ -0,0 +1,117 @@
+package component
+
+import (
+	"halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"testing"
+)
+
+func TestMakeRootless(t *testing.T) {
+	for _, assignUser := range []bool{true, false} {
+		spec := buildStrategies["dockerfile"].taskSpec()
+		makeRootless(&spec, assignUser)
+		buildah := 0
+		for _, step := range spec.Steps {
+			if step.Image != buildahImage {
+				continue
+			}
+			buildah++
+			context := step.SecurityContext
+			if context == nil || context.Privileged != nil || context.RunAsNonRoot == nil || !*context.RunAsNonRoot {
+				t.Errorf("expected '%s' step to run unprivileged as a non-root user, got %+v", step.Name, context)
+				continue
+			}
+			if assignUser != (context.RunAsUser != nil) || (assignUser && *context.RunAsUser != rootlessBuildUser) {
+				t.Errorf("expected '%s' step user to be assigned: %t, got %v", step.Name, assignUser, context.RunAsUser)
+			}
+			if envValue(step.Container, "STORAGE_DRIVER") != "vfs" || envValue(step.Container, "BUILDAH_ISOLATION") != "chroot" {
+				t.Errorf("expected '%s' step to use the vfs storage driver and chroot isolation, got %v", step.Name, step.Env)
+			}
+			if hasVolumeMount(step.Container, "libcontainers") || !hasVolumeMount(step.Container, rootlessStorageVolume) {
+				t.Errorf("expected '%s' step to use the rootless storage volume, got %v", step.Name, step.VolumeMounts)
+			}
+		}
+		if buildah == 0 {
+			t.Errorf("expected the dockerfile Task to have buildah steps")
+		}
+	}
+}
+
+func hasVolumeMount(container corev1.Container, name string) bool {
+	for _, mount := range container.VolumeMounts {
+		if mount.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func envValue(container corev1.Container, name string) string {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+func TestBuildServiceAccountAndRole(t *testing.T) {
+	defer os.Unsetenv(RootlessBuildsEnvVar)
+	cases := []struct {
+		name           string
+		mode           v1beta1.DeploymentMode
+		env            string
+		annotation     string
+		serviceAccount string
+		role           string
+		binding        string
+	}{
+		{name: "privileged", mode: v1beta1.BuildDeploymentMode, serviceAccount: privilegedBuildServiceAccount, role: privilegedBuildRole, binding: privilegedBuildRoleBinding},
+		{name: "rootless", mode: v1beta1.BuildDeploymentMode, annotation: "true", serviceAccount: rootlessBuildServiceAccount, role: rootlessBuildRole, binding: rootlessBuildRoleBinding},
+		{name: "rootless by default", mode: v1beta1.BuildDeploymentMode, env: "true", serviceAccount: rootlessBuildServiceAccount, role: rootlessBuildRole, binding: rootlessBuildRoleBinding},
+		{name: "privileged despite default", mode: v1beta1.BuildDeploymentMode, env: "true", annotation: "false", serviceAccount: privilegedBuildServiceAccount, role: privilegedBuildRole, binding: privilegedBuildRoleBinding},
+		{name: "dev mode", mode: v1beta1.DevDeploymentMode, serviceAccount: rootlessBuildServiceAccount, role: rootlessBuildRole, binding: rootlessBuildRoleBinding},
+	}
+	for _, c := range cases {
+		if err := os.Setenv(RootlessBuildsEnvVar, c.env); err != nil {
+			t.Fatal(err)
+		}
+		hc := &v1beta1.Component{ObjectMeta: metav1.ObjectMeta{Name: "fruits", Namespace: "demo"}}
+		hc.Spec.DeploymentMode = c.mode
+		if len(c.annotation) > 0 {
+			hc.Annotations = map[string]string{RootlessBuildAnnotation: c.annotation}
+		}
+		component := &Component{Component: hc}
+		if sa := ServiceAccountName(hc); sa != c.serviceAccount {
+			t.Errorf("%s: expected '%s' service account, got '%s'", c.name, c.serviceAccount, sa)
+		}
+		if role := component.GetRoleName(); role != c.role {
+			t.Errorf("%s: expected '%s' role, got '%s'", c.name, c.role, role)
+		}
+		if binding := component.GetRoleBindingName(); binding != c.binding {
+			t.Errorf("%s: expected '%s' role binding, got '%s'", c.name, c.binding, binding)
+		}
+	}
+}
+
+func TestIsControlledByComponent(t *testing.T) {
+	controller := true
+	cases := []struct {
+		name       string
+		references []metav1.OwnerReference
+		controlled bool
+	}{
+		{name: "unowned"},
+		{name: "component", references: []metav1.OwnerReference{{Kind: "Component", Name: "fruits", Controller: &controller}}, controlled: true},
+		{name: "owned but not controlled", references: []metav1.OwnerReference{{Kind: "Component", Name: "fruits"}}},
+		{name: "other controller", references: []metav1.OwnerReference{{Kind: "Deployment", Name: "fruits", Controller: &controller}}},
+	}
+	for _, c := range cases {
+		object := &metav1.ObjectMeta{Name: privilegedBuildRoleBinding, OwnerReferences: c.references}
+		if controlled := isControlledByComponent(object); controlled != c.controlled {
+			t.Errorf("%s: expected controlled by a component to be %t, got %t", c.name, c.controlled, controlled)
+		}
+	}
+}
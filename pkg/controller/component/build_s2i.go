@@ -0,0 +1,77 @@
+package component
+
+import (
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// s2iStrategy generates a Dockerfile from the sources using s2i and the component's base image, then builds and pushes
+// it using buildah
+type s2iStrategy struct{}
+
+func init() {
+	registerBuildStrategy("s2i", s2iStrategy{})
+}
+
+func (s2iStrategy) taskName() string {
+	return "s2i-buildah-push"
+}
+
+func (s2iStrategy) taskSpec() v1alpha1.TaskSpec {
+	spec := buildTaskSpec(
+		stringParamSpec("baseImage", "quay.io/halkyonio/spring-boot-maven-s2i", "S2i base image"),
+		stringParamSpec("moduleDirName", ".", "The name of the directory containing the project (maven, ...) to be compiled"),
+	)
+	spec.Steps = append([]v1alpha1.Step{
+		{Container: corev1.Container{
+			// # Generate a Dockerfile using the s2i tool
+			Name:  "generate",
+			Image: "quay.io/openshift-pipeline/s2i",
+			Command: []string{
+				"s2i",
+				"build",
+			},
+			Args: []string{
+				"$(inputs.params.workspacePath)/$(inputs.params.contextPath)",
+				"$(inputs.params.baseImage)",
+				"--as-dockerfile",
+				"/sources/Dockerfile.gen",
+				"--image-scripts-url",
+				"image:///usr/local/s2i",
+				"--loglevel",
+				"5",
+				"--env",
+				"MAVEN_ARGS_APPEND=-pl $(inputs.params.moduleDirName) -Dmaven.repo.local=" + mavenRepositoryPath,
+				"--env",
+				"MAVEN_S2I_ARTIFACT_DIRS=$(inputs.params.moduleDirName)/target",
+				"--env",
+				"S2I_SOURCE_DEPLOYMENTS_FILTER=*.jar",
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					MountPath: "/sources",
+					Name:      "generatedsources"},
+			},
+		}},
+	}, buildahSteps("/sources/Dockerfile.gen", "/sources", corev1.VolumeMount{Name: "generatedsources", MountPath: "/sources"}, mavenRepositoryMount)...)
+	// make the Maven repository available to the generated Dockerfile instructions so that dependencies can be cached
+	for i := range spec.Steps {
+		if build := &spec.Steps[i]; build.Name == buildahBuildStep {
+			build.Args = append([]string{build.Args[0], "--volume", mavenRepositoryPath + ":" + mavenRepositoryPath}, build.Args[1:]...)
+		}
+	}
+	spec.Volumes = buildahVolumes(
+		corev1.Volume{Name: "generatedsources", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		mavenRepositoryVolumeFor(),
+	)
+	return spec
+}
+
+func (s2iStrategy) taskRunParams(c *v1beta1.Component) []v1alpha1.Param {
+	return []v1alpha1.Param{
+		stringParam("baseImage", baseImage(c)),
+		stringParam("moduleDirName", moduleDirName(c)),
+		stringParam("contextPath", contextPath(c)),
+	}
+}
@@ -0,0 +1,132 @@
+package component
+
+import (
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	"sort"
+	"strings"
+)
+
+// defaultBuildStrategy is the strategy used when the component's BuildConfig doesn't specify a type
+const defaultBuildStrategy = "s2i"
+
+const (
+	// digestFile is where steps pushing the image write its digest
+	digestFile = "/tmp/image-digest"
+	// stepResultsPath is the termination message path of steps reporting results, Tekton recording the resource results
+	// written there in the TaskRun status with the v1alpha1 API
+	stepResultsPath = "/dev/termination-log"
+	// taskResultsDir is where steps write the results declared by the Task with the v1beta1 API
+	taskResultsDir = "/tekton/results"
+	// digestResult and cacheUsageResult are the keys of the results reported by builds
+	digestResult     = "digest"
+	cacheUsageResult = "cacheUsage"
+)
+
+// reportDigestCommand reports the digest written in digestFile as the digest result
+var reportDigestCommand = reportResultCommand(digestResult, `"$(cat `+digestFile+`)"`)
+
+// reportsDigest checks whether the steps of the specified Task report the digest of the pushed image
+func reportsDigest(spec v1alpha1.TaskSpec) bool {
+	for _, step := range spec.Steps {
+		for _, arg := range step.Args {
+			if strings.Contains(arg, reportDigestCommand) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reportResultCommand returns a shell command reporting the value of the specified shell expression as the result with
+// the given key, in a way that works with both Tekton API versions
+func reportResultCommand(key, value string) string {
+	return fmt.Sprintf(`{ result=%[2]s; printf '[{"key":"%[1]s","value":"%%s"}]' "$result" > %[3]s; `+
+		`if [ -d %[4]s ]; then printf '%%s' "$result" > %[4]s/%[1]s; fi; }`, key, value, stepResultsPath, taskResultsDir)
+}
+
+// buildStrategy generates the Tekton Task building a component's image from its sources and pushing it to the registry.
+// All strategies get the project cloned under the workspacePath parameter and push the image to the "image" output
+// resource URL. Strategies should report the digest of the pushed image using reportDigestCommand so that deployments
+// can reference it, the image tag being used otherwise.
+type buildStrategy interface {
+	// taskName returns the name of the Task associated with this strategy
+	taskName() string
+	// taskSpec returns the specification of the Task associated with this strategy
+	taskSpec() v1alpha1.TaskSpec
+	// taskRunParams returns the values of the Task parameters to use when building the specified component, Task
+	// defaults being used for unspecified parameters
+	taskRunParams(c *v1beta1.Component) []v1alpha1.Param
+}
+
+var buildStrategies = make(map[string]buildStrategy, 7)
+
+// registerBuildStrategy makes the specified strategy available to components specifying its name as BuildConfig type
+func registerBuildStrategy(name string, strategy buildStrategy) {
+	if _, ok := buildStrategies[name]; ok {
+		panic(fmt.Sprintf("a build strategy named '%s' is already registered", name))
+	}
+	buildStrategies[name] = strategy
+}
+
+// buildStrategyFor returns the strategy to use to build the specified component
+func buildStrategyFor(c *v1beta1.Component) (buildStrategy, error) {
+	name := buildStrategyName(c)
+	strategy, ok := buildStrategies[name]
+	if !ok {
+		known := make([]string, 0, len(buildStrategies))
+		for n := range buildStrategies {
+			known = append(known, n)
+		}
+		sort.Strings(known)
+		return nil, fmt.Errorf("unknown '%s' build type, known types: %s", name, strings.Join(known, ","))
+	}
+	return strategy, nil
+}
+
+func buildStrategyName(c *v1beta1.Component) string {
+	name := strings.ToLower(c.Spec.BuildConfig.Type)
+	if len(name) == 0 {
+		return defaultBuildStrategy
+	}
+	return name
+}
+
+// buildTaskSpec creates a TaskSpec with the git input and image output resources and parameters shared by all strategies
+func buildTaskSpec(params ...v1alpha1.ParamSpec) v1alpha1.TaskSpec {
+	return v1alpha1.TaskSpec{
+		Inputs: &v1alpha1.Inputs{
+			// This input corresponds to a pre-task step as the project will be cloned
+			// under by default the following directory : /workspace/{resource-name}
+			// Resource name has been defined to git hereafter
+			Resources: []v1alpha1.TaskResource{{
+				ResourceDeclaration: v1alpha1.ResourceDeclaration{
+					Name: "git",
+					Type: "git",
+				},
+			}},
+			Params: append([]v1alpha1.ParamSpec{
+				stringParamSpec("contextPath", ".", "The location of the path to run the build from"),
+				stringParamSpec("verifyTLS", "true", "Verify registry certificates"),
+				stringParamSpec("workspacePath", "/workspace/git", "Git path where project is cloned"),
+			}, params...),
+		},
+		Outputs: &v1alpha1.Outputs{
+			Resources: []v1alpha1.TaskResource{{
+				ResourceDeclaration: v1alpha1.ResourceDeclaration{
+					Name: "image",
+					Type: "image",
+				},
+			}},
+		},
+	}
+}
+
+func stringParamSpec(name, defaultValue, description string) v1alpha1.ParamSpec {
+	return v1alpha1.ParamSpec{Name: name, Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: defaultValue}, Description: description}
+}
+
+func stringParam(name, value string) v1alpha1.Param {
+	return v1alpha1.Param{Name: name, Value: v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: value}}
+}
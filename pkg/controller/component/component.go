@@ -47,7 +47,8 @@ func (in *Component) InitDependentResources() ([]framework.DependentResource, er
 	c := in.Component
 	dependents := make([]framework.DependentResource, 0, 20)
 	dependents = append(dependents, in.BaseResource.AddDependentResource(newRole(in), framework.NewOwnedRoleBinding(in), newServiceAccount(c), newPvc(c),
-		newDeployment(c), newService(c), newRoute(c), newIngress(c), newTask(c), newTaskRun(c), newPod(c))...)
+		newDeployment(c), newHorizontalPodAutoscaler(c), newPodDisruptionBudget(c), newService(c), newRoute(c), newIngress(c),
+		newTask(c), newPipeline(c), newTaskRun(c), newPod(c))...)
 
 	requiredCapabilities := c.Spec.Capabilities.Requires
 	for _, config := range requiredCapabilities {
@@ -63,6 +64,10 @@ func (in *Component) InitDependentResources() ([]framework.DependentResource, er
 }
 
 func (in *Component) Delete() error {
+	forgetBuildFailures(in.Component)
+	if err := unlinkSecrets(in.Component); err != nil {
+		return err
+	}
 	if framework.IsTargetClusterRunningOpenShift() {
 		// Delete the ImageStream created by OpenShift if it exists as the Component doesn't own this resource
 		// when it is created during build deployment mode
@@ -85,9 +90,29 @@ func (in *Component) Delete() error {
 	return nil
 }
 
+// prepareBuild sets up the resources the builds of the specified component need which aren't managed as dependents: the
+// build cache, which outlives the component when retained, and the git secret, which belongs to the user
+func prepareBuild(c *halkyon.Component) error {
+	if err := ensureBuildCache(c); err != nil {
+		return err
+	}
+	return annotateGitSecret(c)
+}
+
 func (in *Component) CreateOrUpdate() (err error) {
 	if halkyon.BuildDeploymentMode == in.Spec.DeploymentMode {
-		err = in.CreateOrUpdateDependents()
+		err = prepareBuild(in.Component)
+		if err == nil {
+			err = in.CreateOrUpdateDependents()
+		}
+		if err == nil {
+			// images are only recorded as deployed once the deployment running them was updated
+			err = recordBuildDeploymentImage(in.Component)
+		}
+		if err == nil {
+			// only keep a bounded history of builds
+			err = pruneBuildHistory(in.Component)
+		}
 	} else {
 		// Enrich Component with k8s recommend Labels
 		in.ObjectMeta.Labels = PopulateK8sLabels(in.Component, "Backend")
@@ -101,6 +126,42 @@ func (in *Component) CreateOrUpdate() (err error) {
 		err = in.CreateOrUpdateDependents()
 	}
 
+	if err == nil {
+		// dependents which aren't needed anymore aren't deleted by the framework
+		err = removeUnusedScalingResources(in.Component)
+	}
+
+	if err == nil {
+		err = removeUnusedPipeline(in.Component)
+	}
+
+	if err == nil {
+		// builds used a Task shared by the components of the namespace before each component got its own
+		err = removeLegacyTask(in.Component)
+	}
+
+	if err == nil {
+		// rootless builds use their own service account, the privileged one only keeping its SCC while builds need it
+		err = unlinkUnusedSecrets(in.Component)
+		if err == nil {
+			err = removeUnusedPrivilegedRole(in.Component)
+		}
+	}
+
+	if err == nil {
+		// complete the switch between deployment modes once the traffic is routed to the deployment of the current mode
+		err = removePreviousModeDeployment(in.Component)
+	}
+
+	if err == nil {
+		// progressively roll out new images, checking again on the rollout until it completes
+		var inProgress bool
+		inProgress, err = progressRollout(in.Component)
+		if inProgress {
+			in.SetNeedsRequeue(true)
+		}
+	}
+
 	if err != nil {
 		return err
 	}
@@ -157,7 +218,7 @@ func (in *Component) updateComponentWithLinkInfo(c halkyon.RequiredCapabilityCon
 	}
 	deployment := d.(*appsv1.Deployment)
 	containers := deployment.Spec.Template.Spec.Containers
-	secretName := fmt.Sprintf("%s-config", c.BoundTo) // todo: we need to retrieve the secret name from the capability
+	secretName := capabilitySecretName(c.BoundTo)
 
 	// Check if EnvFrom already exists
 	// If this is the case, exit without error
@@ -185,6 +246,28 @@ func (in *Component) updateComponentWithLinkInfo(c halkyon.RequiredCapabilityCon
 	return
 }
 
+// capabilitySecretName returns the name of the secret holding the configuration of the specified capability
+func capabilitySecretName(capability string) string {
+	return fmt.Sprintf("%s-config", capability) // todo: we need to retrieve the secret name from the capability
+}
+
+// linkedCapabilitiesEnvFrom returns the env sources exposing the configuration of the capabilities the specified
+// component is bound to, so that deployments are created already linked whichever the deployment mode. The sources are
+// optional so that pods start even if the configuration of a capability isn't available yet, which spares reading Secrets.
+func linkedCapabilitiesEnvFrom(c *halkyon.Component) []corev1.EnvFromSource {
+	var envFrom []corev1.EnvFromSource
+	for _, required := range c.Spec.Capabilities.Requires {
+		if len(required.BoundTo) == 0 {
+			continue
+		}
+		source := addSecretAsEnvFromSource(capabilitySecretName(required.BoundTo))
+		optional := true
+		source.SecretRef.Optional = &optional
+		envFrom = append(envFrom, source)
+	}
+	return envFrom
+}
+
 func addSecretAsEnvFromSource(secretName string) corev1.EnvFromSource {
 	return corev1.EnvFromSource{
 		SecretRef: &corev1.SecretEnvSource{
@@ -232,6 +315,62 @@ func (in *Component) CheckValidity() error {
 	if in.Spec.Port == 0 {
 		return fmt.Errorf("component '%s' must provide a port", in.Name)
 	}
+	// Check that env vars referencing other sources are valid
+	if _, err := getEnvRefs(in.Component); err != nil {
+		return err
+	}
+	// Check that the build type is known
+	if halkyon.BuildDeploymentMode == in.Spec.DeploymentMode {
+		if _, err := buildStrategyFor(in.Component); err != nil {
+			return err
+		}
+	}
+	// Check that scaling configuration is valid
+	if _, err := getScalingConfig(in.Component); err != nil {
+		return err
+	}
+	// Check that builds can be run
+	if halkyon.BuildDeploymentMode == in.Spec.DeploymentMode {
+		if _, err := buildExecutor(); err != nil {
+			return err
+		}
+	}
+	// Check that the registry configuration is valid
+	if _, err := getRegistryConfig(in.Component); err != nil {
+		return err
+	}
+	// Check that the git configuration is valid
+	if _, err := getGitConfig(in.Component); err != nil {
+		return err
+	}
+	// Check that the Dockerfile path is valid
+	if _, err := getDockerfile(in.Component); err != nil {
+		return err
+	}
+	// Check that the rootless build setting is valid
+	if _, err := isRootlessBuild(in.Component); err != nil {
+		return err
+	}
+	// Check that the pipeline stages are known and properly ordered
+	if _, _, err := getPipelineConfig(in.Component); err != nil {
+		return err
+	}
+	// Check that the rollout strategy is valid
+	if _, err := getRolloutConfig(in.Component); err != nil {
+		return err
+	}
+	// Check that the debug configuration is valid
+	if _, _, err := getDebugConfig(in.Component); err != nil {
+		return err
+	}
+	// Check that the build cache configuration is valid
+	if _, _, err := getBuildCacheConfig(in.Component); err != nil {
+		return err
+	}
+	// Check that specified resources requirements can be parsed
+	if _, err := unmarshalAnnotation(in.Component, ResourcesAnnotation, &corev1.ResourceRequirements{}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -239,12 +378,20 @@ func (in *Component) Owner() framework.SerializableResource {
 	return in.GetUnderlyingAPIResource()
 }
 
+// GetRoleName returns the name of the Role used by builds, which only grants the privileged SCC if builds need it
 func (in *Component) GetRoleName() string {
-	return "image-scc-privileged-role"
+	if needsPrivilegedBuilds(in.Component) {
+		return privilegedBuildRole
+	}
+	return rootlessBuildRole
 }
 
+// GetRoleBindingName returns the name of the RoleBinding granting the Role used by builds to their service account
 func (in *Component) GetRoleBindingName() string {
-	return "use-image-scc-privileged"
+	if needsPrivilegedBuilds(in.Component) {
+		return privilegedBuildRoleBinding
+	}
+	return rootlessBuildRoleBinding
 }
 
 func (in *Component) GetAssociatedRoleName() string {
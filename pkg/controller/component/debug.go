@@ -0,0 +1,173 @@
+package component
+
+import (
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// DebugPortAttributeKey records, on the deployment condition, the port debuggers should connect to once forwarded
+const DebugPortAttributeKey = "DebugPort"
+
+// mainPortName names the port of the component on the container and the service, which routes target, and debugPortName
+// the debug port
+const (
+	mainPortName  = "http"
+	debugPortName = "debug"
+)
+
+// Debug agents runtimes can use
+const (
+	// jdwpDebugger is the Java Debug Wire Protocol agent of JVM runtimes
+	jdwpDebugger = "jdwp"
+	// inspectorDebugger is the inspector of Node.js runtimes
+	inspectorDebugger = "inspector"
+)
+
+// defaultDebugPorts are the ports debug agents listen on by default
+var defaultDebugPorts = map[string]int32{jdwpDebugger: 5005, inspectorDebugger: 9229}
+
+// defaultDebugEnvs are the env vars the debug agents are enabled with by default
+var defaultDebugEnvs = map[string]string{jdwpDebugger: "JAVA_TOOL_OPTIONS", inspectorDebugger: "NODE_OPTIONS"}
+
+// debugConfig defines how the application of a dev mode component is debugged
+type debugConfig struct {
+	// Port is the port the debug agent listens on, defaulting to the agent's usual port
+	Port int32 `json:"port,omitempty"`
+	// Suspend makes the application wait for a debugger to attach before starting
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// getDebugConfig returns the debug configuration of the specified component, which only applies in dev mode
+func getDebugConfig(c *v1beta1.Component) (config debugConfig, enabled bool, err error) {
+	if v1beta1.BuildDeploymentMode == c.Spec.DeploymentMode {
+		return config, false, nil
+	}
+	enabled, err = unmarshalAnnotation(c, DebugAnnotation, &config)
+	if err != nil || !enabled {
+		return config, false, err
+	}
+	if config.Port < 0 || config.Port > 65535 {
+		return config, false, fmt.Errorf("invalid port %d in '%s' annotation", config.Port, DebugAnnotation)
+	}
+	// the agent listens on the default port of the runtime's debugger unless a port is specified
+	port := config.Port
+	if port == 0 {
+		runtime, err := getImageInfo(c)
+		if err != nil {
+			return config, false, err
+		}
+		port = config.port(runtime.devMode.Debugger)
+	}
+	if port == c.Spec.Port {
+		return config, false, fmt.Errorf("debug port %d is already used by the component, specify another one in '%s' annotation", port, DebugAnnotation)
+	}
+	return config, true, nil
+}
+
+// port returns the port the agent of the specified debugger listens on
+func (in debugConfig) port(debugger string) int32 {
+	if in.Port > 0 {
+		return in.Port
+	}
+	return defaultDebugPorts[debugger]
+}
+
+// agentOptions returns the options enabling the specified debugger, listening on all interfaces so that the port can be
+// forwarded
+func (in debugConfig) agentOptions(debugger string) string {
+	port := in.port(debugger)
+	if debugger == inspectorDebugger {
+		if in.Suspend {
+			return fmt.Sprintf("--inspect-brk=0.0.0.0:%d", port)
+		}
+		return fmt.Sprintf("--inspect=0.0.0.0:%d", port)
+	}
+	suspend := "n"
+	if in.Suspend {
+		suspend = "y"
+	}
+	return fmt.Sprintf("-agentlib:jdwp=transport=dt_socket,server=y,suspend=%s,address=*:%d", suspend, port)
+}
+
+// debugOptionsEnvVar holds the options enabling the debug agent, which only the debugged program passes on to the runtime
+const debugOptionsEnvVar = "HALKYON_DEBUG_OPTIONS"
+
+// addDebugAgent provides the options enabling the debug agent of the specified component's runtime, if debugging is
+// enabled. They are passed with a dedicated env var rather than the one the runtime reads them from so that they don't
+// affect the other programs of the container, e.g. making the build wait for a debugger or bind the debug port.
+func addDebugAgent(c *v1beta1.Component, envs map[string]corev1.EnvVar) error {
+	config, enabled, err := getDebugConfig(c)
+	if err != nil || !enabled {
+		return err
+	}
+	runtime, err := getImageInfo(c)
+	if err != nil {
+		return err
+	}
+	envs[debugOptionsEnvVar] = corev1.EnvVar{Name: debugOptionsEnvVar, Value: config.agentOptions(runtime.devMode.Debugger)}
+	return nil
+}
+
+// devModeFor returns the dev mode configuration of the specified component's runtime, flagged as debugged when debugging
+// is enabled so that its debug program appends the debug options to the env var the runtime reads them from
+func devModeFor(c *v1beta1.Component, runtime Runtime) devModeConfig {
+	devMode := runtime.devMode
+	if _, enabled, err := getDebugConfig(c); err == nil && enabled {
+		devMode.debugged = true
+	}
+	return devMode
+}
+
+// debugPort returns the port the debug agent of the specified component listens on, 0 if debugging isn't enabled
+func debugPort(c *v1beta1.Component) int32 {
+	config, enabled, err := getDebugConfig(c)
+	if err != nil || !enabled {
+		return 0
+	}
+	runtime, err := getImageInfo(c)
+	if err != nil {
+		return 0
+	}
+	return config.port(runtime.devMode.Debugger)
+}
+
+// containerPortsFor returns the ports of the specified component's runtime container, including the debug port when
+// debugging is enabled
+func containerPortsFor(c *v1beta1.Component) []corev1.ContainerPort {
+	ports := []corev1.ContainerPort{{
+		ContainerPort: c.Spec.Port,
+		Name:          mainPortName,
+		Protocol:      "TCP",
+	}}
+	if port := debugPort(c); port > 0 {
+		ports = append(ports, corev1.ContainerPort{ContainerPort: port, Name: debugPortName, Protocol: "TCP"})
+	}
+	return ports
+}
+
+// servicePortsFor returns the ports of the specified component's service, including the debug port when debugging is
+// enabled. The main port is always named so that routes only target it, whether the debug port is exposed or not.
+func servicePortsFor(c *v1beta1.Component) []corev1.ServicePort {
+	ports := []corev1.ServicePort{
+		{
+			Name: mainPortName,
+			TargetPort: intstr.IntOrString{
+				Type:   intstr.Int,
+				IntVal: c.Spec.Port,
+			},
+			Port:     c.Spec.Port,
+			Protocol: "TCP",
+		},
+	}
+	if port := debugPort(c); port > 0 {
+		ports = append(ports, corev1.ServicePort{
+			Name:       debugPortName,
+			TargetPort: intstr.FromInt(int(port)),
+			Port:       port,
+			Protocol:   "TCP",
+		})
+	}
+	return ports
+}
@@ -0,0 +1,80 @@
+package component
+
+import (
+	routev1 "github.com/openshift/api/route/v1"
+	"halkyon.io/api/component/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"testing"
+)
+
+func TestGetDebugConfig(t *testing.T) {
+	defer setRuntimes(springBootRuntime(nil), nodeRuntime())()
+	cases := []struct {
+		name       string
+		runtime    string
+		port       int32
+		mode       v1beta1.DeploymentMode
+		annotation string
+		enabled    bool
+		valid      bool
+	}{
+		{name: "disabled", port: 8080, mode: v1beta1.DevDeploymentMode, valid: true},
+		{name: "default port", port: 8080, mode: v1beta1.DevDeploymentMode, annotation: "{}", enabled: true, valid: true},
+		{name: "build mode", port: 8080, mode: v1beta1.BuildDeploymentMode, annotation: "{}", valid: true},
+		{name: "default port used", port: 5005, mode: v1beta1.DevDeploymentMode, annotation: "{}", valid: false},
+		{name: "default port of other debugger", port: 5005, runtime: "nodejs", mode: v1beta1.DevDeploymentMode, annotation: "{}", enabled: true, valid: true},
+		{name: "default inspector port used", port: 9229, runtime: "nodejs", mode: v1beta1.DevDeploymentMode, annotation: "{}", valid: false},
+		{name: "specified port", port: 5005, mode: v1beta1.DevDeploymentMode, annotation: `{"port":5006}`, enabled: true, valid: true},
+		{name: "specified port used", port: 8080, mode: v1beta1.DevDeploymentMode, annotation: `{"port":8080}`, valid: false},
+		{name: "invalid port", port: 8080, mode: v1beta1.DevDeploymentMode, annotation: `{"port":70000}`, valid: false},
+	}
+	for _, c := range cases {
+		hc := springBootComponent(c.mode)
+		if len(c.runtime) > 0 {
+			hc.Spec.Runtime = c.runtime
+			hc.Spec.Version = "12"
+		}
+		hc.Spec.Port = c.port
+		if len(c.annotation) > 0 {
+			hc.Annotations = map[string]string{DebugAnnotation: c.annotation}
+		}
+		_, enabled, err := getDebugConfig(hc)
+		if c.valid != (err == nil) {
+			t.Errorf("%s: expected validity to be %t, got error: %v", c.name, c.valid, err)
+			continue
+		}
+		if enabled != c.enabled {
+			t.Errorf("%s: expected debugging to be enabled: %t, got %t", c.name, c.enabled, enabled)
+		}
+	}
+}
+
+func TestServicePortsFor(t *testing.T) {
+	defer setRuntimes(springBootRuntime(nil))()
+	c := springBootComponent(v1beta1.DevDeploymentMode)
+	ports := servicePortsFor(c)
+	if len(ports) != 1 || ports[0].Name != mainPortName || ports[0].Port != 8080 {
+		t.Errorf("expected named main port only, got %+v", ports)
+	}
+
+	c.Annotations = map[string]string{DebugAnnotation: "{}"}
+	ports = servicePortsFor(c)
+	if len(ports) != 2 || ports[0].Name != mainPortName || ports[1].Name != debugPortName || ports[1].Port != 5005 {
+		t.Errorf("expected named main and debug ports, got %+v", ports)
+	}
+	if container := containerPortsFor(c); len(container) != 2 || container[0].Name != mainPortName || container[1].ContainerPort != 5005 {
+		t.Errorf("expected container to expose main and debug ports, got %+v", container)
+	}
+}
+
+func TestRouteSpecFor(t *testing.T) {
+	c := springBootComponent(v1beta1.DevDeploymentMode)
+	spec := routeSpecFor(c)
+	if spec.To.Kind != "Service" || spec.To.Name != c.Name {
+		t.Errorf("expected route to target '%s' service, got %+v", c.Name, spec.To)
+	}
+	expected := &routev1.RoutePort{TargetPort: intstr.FromString(mainPortName)}
+	if spec.Port == nil || *spec.Port != *expected {
+		t.Errorf("expected route to only target the main port of the service, got %+v", spec.Port)
+	}
+}
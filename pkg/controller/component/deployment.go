@@ -1,13 +1,16 @@
 package component
 
 import (
+	"encoding/json"
+	"fmt"
 	component "halkyon.io/api/component/v1beta1"
 	"halkyon.io/api/v1beta1"
 	"halkyon.io/operator-framework"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/runtime"
-	"reflect"
+	"strconv"
 )
 
 type deployment struct {
@@ -49,6 +52,30 @@ func (res deployment) GetCondition(underlying runtime.Object, err error) *v1beta
 		cond.Type = v1beta1.DependentReady
 		cond.Reason = string(v1beta1.DependentReady)
 		cond.Message = ""
+		if history := deployedImages(c); len(history) > 0 {
+			value, _ := json.Marshal(history)
+			cond.SetAttribute(DeployedImagesAttributeKey, string(value))
+		}
+		if requested := getRollback(c); len(requested) > 0 {
+			if rollback := requestedRollback(c); len(rollback) > 0 {
+				cond.Message = fmt.Sprintf("rolled back to %s, new builds aren't deployed until '%s' annotation is removed", rollback, RollbackAnnotation)
+				cond.SetAttribute(RolledBackToAttributeKey, rollback)
+			} else {
+				cond.Message = fmt.Sprintf("cannot roll back to %s: not among the images listed by the '%s' attribute", requested, DeployedImagesAttributeKey)
+				cond.SetAttribute(RollbackFailedAttributeKey, requested)
+			}
+			return
+		}
+		if port := debugPort(c); port > 0 {
+			cond.Message = fmt.Sprintf("debug agent listening on port %d, forward it using 'kubectl port-forward service/%s %d'", port, framework.DefaultDependentResourceNameFor(c), port)
+			cond.SetAttribute(DebugPortAttributeKey, strconv.Itoa(int(port)))
+		}
+		if r, e := currentRollout(c); e == nil {
+			if phase, progress := r.phase(c); len(phase) > 0 {
+				cond.Message = progress
+				cond.SetAttribute(RolloutAttributeKey, phase)
+			}
+		}
 	})
 }
 
@@ -56,37 +83,125 @@ func (res deployment) Update(toUpdate runtime.Object) (bool, runtime.Object, err
 	deployment := toUpdate.(*appsv1.Deployment)
 	container := deployment.Spec.Template.Spec.Containers[0]
 	c := res.ownerAsComponent()
-	envAsMap, err := getEnvAsMap(c)
+	envVars, err := populatePodEnvVar(c)
 	if err != nil {
 		return false, nil, err
 	}
-	existingAsMap := make(map[string]string, len(container.Env))
-	for _, envVar := range container.Env {
-		existingAsMap[envVar.Name] = envVar.Value
+	updated := false
+	if !sameEnvVars(envVars, container.Env) {
+		container.Env = envVars
+		updated = true
+	}
+	runtimeImage, err := getImageInfo(c)
+	if err != nil {
+		return false, nil, err
+	}
+	resources, err := getResourceRequirements(c, runtimeImage)
+	if err != nil {
+		return false, nil, err
 	}
-	if !reflect.DeepEqual(envAsMap, existingAsMap) {
-		newEnvVars := make([]corev1.EnvVar, 0, len(envAsMap))
-		for k, v := range envAsMap {
-			newEnvVars = append(newEnvVars, corev1.EnvVar{Name: k, Value: v})
+	if !equality.Semantic.DeepEqual(resources, container.Resources) {
+		container.Resources = resources
+		initContainers := deployment.Spec.Template.Spec.InitContainers
+		for i := range initContainers {
+			initContainers[i].Resources = resources
 		}
-		container.Env = newEnvVars
-		deployment.Spec.Template.Spec.Containers[0] = container
-		return true, deployment, nil
+		updated = true
+	}
+	if ports := containerPortsFor(c); !equality.Semantic.DeepEqual(ports, container.Ports) {
+		container.Ports = ports
+		updated = true
+	}
+	probes, err := getProbes(c)
+	if err != nil {
+		return false, nil, err
+	}
+	wanted := container.DeepCopy()
+	setProbes(wanted, probes, c.Spec.Port)
+	if !equality.Semantic.DeepEqual(wanted.LivenessProbe, container.LivenessProbe) || !equality.Semantic.DeepEqual(wanted.ReadinessProbe, container.ReadinessProbe) {
+		container.LivenessProbe = wanted.LivenessProbe
+		container.ReadinessProbe = wanted.ReadinessProbe
+		updated = true
+	}
+	scaling, err := getScalingConfig(c)
+	if err != nil {
+		return false, nil, err
 	}
-	return false, deployment, nil
+	replicas := scaling.desiredReplicas()
+	if component.BuildDeploymentMode == c.Spec.DeploymentMode {
+		image, pullPolicy := builtImage(c, container.Image)
+		r, err := currentRollout(c)
+		if err != nil {
+			return false, nil, err
+		}
+		if rollback := requestedRollback(c); len(rollback) > 0 {
+			// rollbacks are immediate, whichever the rollout strategy
+			image, pullPolicy = rollback, corev1.PullIfNotPresent
+		} else if r.holds(container.Image, image) {
+			// the new image runs in the candidate deployment until it's promoted
+			image, pullPolicy = container.Image, container.ImagePullPolicy
+		}
+		if image != container.Image || pullPolicy != container.ImagePullPolicy {
+			container.Image = image
+			container.ImagePullPolicy = pullPolicy
+			updated = true
+		}
+		replicas = buildReplicas(c, scaling, pullPolicy, deployment.Spec.Replicas)
+	} else {
+		// the dev mode configuration of the runtime might have changed since the deployment was created
+		devMode := devModeFor(c, runtimeImage)
+		wanted := container.DeepCopy()
+		setDevModeCommand(wanted, c, devMode)
+		if !equality.Semantic.DeepEqual(wanted.Command, container.Command) || !equality.Semantic.DeepEqual(wanted.Args, container.Args) ||
+			!equality.Semantic.DeepEqual(wanted.VolumeMounts, container.VolumeMounts) {
+			container.Command = wanted.Command
+			container.Args = wanted.Args
+			container.VolumeMounts = wanted.VolumeMounts
+			updated = true
+		}
+		supervisor, err := supervisorContainerFor(devMode, resources)
+		if err != nil {
+			return false, nil, err
+		}
+		initContainers := deployment.Spec.Template.Spec.InitContainers
+		if len(initContainers) != 1 || initContainers[0].Image != supervisor.Image || !sameEnvVars(supervisor.Env, initContainers[0].Env) ||
+			!equality.Semantic.DeepEqual(supervisor.VolumeMounts, initContainers[0].VolumeMounts) {
+			deployment.Spec.Template.Spec.InitContainers = []corev1.Container{supervisor}
+			updated = true
+		}
+	}
+	if replicas != nil && (deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != *replicas) {
+		deployment.Spec.Replicas = replicas
+		updated = true
+	}
+	deployment.Spec.Template.Spec.Containers[0] = container
+	return updated, deployment, nil
 }
 
-func populatePodEnvVar(component *component.Component) ([]corev1.EnvVar, error) {
-	tmpEnvVar, err := getEnvAsMap(component)
+// javaAppJarAnnotation specifies the jar build mode components run, overriding the JAVA_APP_JAR env var
+const javaAppJarAnnotation = "app.openshift.io/java-app-jar"
+
+// populatePodEnvVar returns the env vars of the specified component's runtime container, the jar to run being taken from
+// the javaAppJarAnnotation in build mode if specified and the commands of the supervisord programs reading them from the
+// env being provided in dev mode
+func populatePodEnvVar(c *component.Component) ([]corev1.EnvVar, error) {
+	envs, err := getEnvAsMap(c)
 	if err != nil {
 		return nil, err
 	}
-
-	// Convert Map to Slice
-	newEnvVars := make([]corev1.EnvVar, 0, len(tmpEnvVar))
-	for k, v := range tmpEnvVar {
-		newEnvVars = append(newEnvVars, corev1.EnvVar{Name: k, Value: v})
+	if component.BuildDeploymentMode == c.Spec.DeploymentMode {
+		if jar := c.Annotations[javaAppJarAnnotation]; len(jar) > 0 {
+			envs["JAVA_APP_JAR"] = corev1.EnvVar{Name: "JAVA_APP_JAR", Value: jar}
+		}
+	} else {
+		runtime, err := getImageInfo(c)
+		if err != nil {
+			return nil, err
+		}
+		devModeFor(c, runtime).addCommandEnvVars(envs)
 	}
-
-	return newEnvVars, nil
+	if err := addDebugAgent(c, envs); err != nil {
+		return nil, err
+	}
+	return sortedEnvVars(envs), nil
 }
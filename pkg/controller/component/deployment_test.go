@@ -0,0 +1,52 @@
+package component
+
+import (
+	"halkyon.io/api/component/v1beta1"
+	halkyon "halkyon.io/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"reflect"
+	"testing"
+)
+
+func TestModeSwitchKeepsContainerConfig(t *testing.T) {
+	defer setRuntimes(springBootRuntime(nil))()
+	hc := springBootComponent(v1beta1.DevDeploymentMode)
+	hc.Spec.Envs = []halkyon.NameValuePair{{Name: "SPRING_PROFILES_ACTIVE", Value: "openshift"}}
+	hc.Spec.Capabilities.Requires = []v1beta1.RequiredCapabilityConfig{{BoundTo: "postgres-db"}, {}}
+
+	type containerConfig struct {
+		env     []corev1.EnvVar
+		envFrom []corev1.EnvFromSource
+		ports   []corev1.ContainerPort
+	}
+	configFor := func(mode v1beta1.DeploymentMode) containerConfig {
+		hc.Spec.DeploymentMode = mode
+		env, err := populatePodEnvVar(hc)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		return containerConfig{env: env, envFrom: linkedCapabilitiesEnvFrom(hc), ports: containerPortsFor(hc)}
+	}
+
+	dev := configFor(v1beta1.DevDeploymentMode)
+	build := configFor(v1beta1.BuildDeploymentMode)
+	if !reflect.DeepEqual(dev, build) {
+		t.Errorf("expected switching from dev to build mode to keep the container config %+v, got %+v", dev, build)
+	}
+	if back := configFor(v1beta1.DevDeploymentMode); !reflect.DeepEqual(back, build) {
+		t.Errorf("expected switching from build to dev mode to keep the container config %+v, got %+v", build, back)
+	}
+
+	if envValue(corev1.Container{Env: dev.env}, "JARPATTERN") != "*.jar" || envValue(corev1.Container{Env: dev.env}, "SPRING_PROFILES_ACTIVE") != "openshift" {
+		t.Errorf("expected runtime and component env vars, got %+v", dev.env)
+	}
+	if len(dev.envFrom) != 1 || dev.envFrom[0].SecretRef == nil || dev.envFrom[0].SecretRef.Name != capabilitySecretName("postgres-db") {
+		t.Fatalf("expected the configuration of the bound capability only, got %+v", dev.envFrom)
+	}
+	if optional := dev.envFrom[0].SecretRef.Optional; optional == nil || !*optional {
+		t.Errorf("expected the capability configuration to be optional, got %+v", dev.envFrom[0].SecretRef)
+	}
+	if len(dev.ports) != 1 || dev.ports[0].ContainerPort != 8080 {
+		t.Errorf("expected the component port, got %+v", dev.ports)
+	}
+}
@@ -22,27 +22,26 @@ func (res deployment) installDev(empty bool) (runtime.Object, error) {
 		if err != nil {
 			return nil, err
 		}
-		runtimeContainer.Args = []string{
-			"-c",
-			"/var/lib/supervisord/conf/supervisor.conf",
+		runtimeImage, err := getImageInfo(c)
+		if err != nil {
+			return nil, err
+		}
+		// the runtime declares the commands building and running the pushed code and where the pushed files are expected
+		devMode := devModeFor(c, runtimeImage)
+		setDevModeCommand(&runtimeContainer, c, devMode)
+		runtimeContainer.EnvFrom = linkedCapabilitiesEnvFrom(c)
+		runtimeContainer.Ports = containerPortsFor(c)
+		probes, err := getProbes(c)
+		if err != nil {
+			return nil, err
 		}
-		runtimeContainer.Command = []string{"/var/lib/supervisord/bin/supervisord"}
-		runtimeContainer.Ports = []corev1.ContainerPort{{
-			ContainerPort: c.Spec.Port,
-			Name:          "http",
-			Protocol:      "TCP",
-		}}
-		runtimeContainer.VolumeMounts = append(runtimeContainer.VolumeMounts, corev1.VolumeMount{Name: c.Spec.Storage.Name, MountPath: "/deployments"})
-		runtimeContainer.VolumeMounts = append(runtimeContainer.VolumeMounts, corev1.VolumeMount{Name: c.Spec.Storage.Name, MountPath: "/usr/src"})
-		runtimeContainer.VolumeMounts = append(runtimeContainer.VolumeMounts, corev1.VolumeMount{Name: c.Spec.Storage.Name, MountPath: "/tmp/artefacts"})
+		setProbes(&runtimeContainer, probes, c.Spec.Port)
 
 		// create the supervisor init container
-		supervisorContainer, err := getBaseContainerFor(getSupervisor())
+		supervisorContainer, err := supervisorContainerFor(devMode, runtimeContainer.Resources)
 		if err != nil {
 			return nil, err
 		}
-		supervisorContainer.TerminationMessagePath = "/dev/termination-log"
-		supervisorContainer.TerminationMessagePolicy = "File"
 
 		dep.ObjectMeta = metav1.ObjectMeta{
 			Name:      res.Name(),
@@ -78,6 +77,36 @@ func (res deployment) installDev(empty bool) (runtime.Object, error) {
 	return dep, nil
 }
 
+// setDevModeCommand makes the specified runtime container run supervisord, which builds and runs the pushed code as the
+// given dev mode configuration specifies, and mounts the volumes holding supervisord and the pushed files
+func setDevModeCommand(container *corev1.Container, c *component.Component, devMode devModeConfig) {
+	container.Command = []string{devMode.supervisordBinary()}
+	container.Args = []string{"-c", devMode.supervisordConf()}
+	// the supervisor init container copies supervisord and generates its configuration in supervisordDir, so the shared
+	// volume is always mounted there for the paths found in the configuration to resolve
+	container.VolumeMounts = []corev1.VolumeMount{{Name: "shared-data", MountPath: supervisordDir}}
+	if devMode.SupervisordDir != supervisordDir {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: "shared-data", MountPath: devMode.SupervisordDir})
+	}
+	for _, path := range devMode.MountPaths {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: c.Spec.Storage.Name, MountPath: path})
+	}
+}
+
+// supervisorContainerFor returns the init container copying supervisord, configured with the programs of the specified dev
+// mode configuration, to the shared volume
+func supervisorContainerFor(devMode devModeConfig, resources corev1.ResourceRequirements) (corev1.Container, error) {
+	container, err := getBaseContainerFor(getSupervisor(devMode))
+	if err != nil {
+		return container, err
+	}
+	container.TerminationMessagePath = "/dev/termination-log"
+	container.TerminationMessagePolicy = "File"
+	// the init container needs to abide by the same quotas as the runtime one
+	container.Resources = resources
+	return container, nil
+}
+
 func getBaseContainerFor(component *component.Component) (corev1.Container, error) {
 	runtimeImage, err := getImageInfo(component)
 	if err != nil {
@@ -89,13 +118,19 @@ func getBaseContainerFor(component *component.Component) (corev1.Container, erro
 		return corev1.Container{}, err
 	}
 
+	resources, err := getResourceRequirements(component, runtimeImage)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
 	container := corev1.Container{
 		Env:             env,
 		Image:           runtimeImage.RegistryRef,
 		ImagePullPolicy: corev1.PullAlways,
 		Name:            component.Name,
+		Resources:       resources,
 		VolumeMounts: []corev1.VolumeMount{
-			{Name: "shared-data", MountPath: "/var/lib/supervisord"},
+			{Name: "shared-data", MountPath: supervisordDir},
 		},
 	}
 	return container, nil
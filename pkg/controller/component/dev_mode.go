@@ -0,0 +1,254 @@
+package component
+
+import (
+	"encoding/json"
+	"fmt"
+	halkyon "halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// envVarName matches the names of the env vars debug options can be passed with
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// supervisordDir is where the supervisor init container copies the supervisord binary and its configuration
+const supervisordDir = "/var/lib/supervisord"
+
+// devModeConfig defines how the runtime container of dev mode components runs the pushed code: the supervisord programs
+// building and running it and where the pushed files end up
+type devModeConfig struct {
+	// Commands are the supervisord programs the pushed code is built and run with
+	Commands []devModeCommand `json:"commands,omitempty"`
+	// SupervisordDir is where the supervisord binary and configuration are made available in the runtime container
+	SupervisordDir string `json:"supervisordDir,omitempty"`
+	// MountPaths are the paths at which the volume receiving the pushed files is mounted in the runtime container
+	MountPaths []string `json:"mountPaths,omitempty"`
+	// Debugger is the debug agent of the runtime, either jdwp or inspector, and DebugEnv the env var the options enabling
+	// it are passed with
+	Debugger string `json:"debugger,omitempty"`
+	DebugEnv string `json:"debugEnv,omitempty"`
+	// DebugProgram is the program running the application, the only one getting the debug options, which defaults to the
+	// run program, or to the first program starting with the pod if there is none
+	DebugProgram string `json:"debugProgram,omitempty"`
+	// debugged tells whether the debug program is started with the debug options
+	debugged bool
+}
+
+// devModeCommand is a supervisord program, only started along with supervisord if Autostart is true
+type devModeCommand struct {
+	Name      string `json:"name"`
+	Command   string `json:"command"`
+	Autostart bool   `json:"autostart,omitempty"`
+}
+
+// defaultDevModeConfig is used for runtimes which don't specify their dev mode configuration, building the pushed code
+// on demand and running it as soon as the pod starts
+var defaultDevModeConfig = devModeConfig{
+	Commands: []devModeCommand{
+		{Name: "build", Command: "/usr/local/bin/build"},
+		{Name: "run", Command: "/usr/local/bin/run", Autostart: true},
+	},
+	SupervisordDir: supervisordDir,
+	MountPaths:     []string{"/deployments", "/usr/src", "/tmp/artefacts"},
+	Debugger:       jdwpDebugger,
+}
+
+// withDefaults validates the specified dev mode configuration of the given runtime, using the default values for the
+// settings it doesn't specify
+func (in devModeConfig) withDefaults(runtime string) (devModeConfig, error) {
+	if len(in.Commands) == 0 {
+		in.Commands = defaultDevModeConfig.Commands
+	}
+	if len(in.SupervisordDir) == 0 {
+		in.SupervisordDir = defaultDevModeConfig.SupervisordDir
+	}
+	if len(in.MountPaths) == 0 {
+		in.MountPaths = defaultDevModeConfig.MountPaths
+	}
+	if len(in.Debugger) == 0 {
+		in.Debugger = defaultDevModeConfig.Debugger
+	}
+	if _, ok := defaultDebugEnvs[in.Debugger]; !ok {
+		return in, fmt.Errorf("unknown debugger '%s' in '%s' annotation of '%s' runtime, must be either %s or %s", in.Debugger, DevModeAnnotation, runtime, jdwpDebugger, inspectorDebugger)
+	}
+	if len(in.DebugEnv) == 0 {
+		in.DebugEnv = defaultDebugEnvs[in.Debugger]
+	}
+	if !envVarName.MatchString(in.DebugEnv) {
+		return in, fmt.Errorf("invalid debug env var '%s' in '%s' annotation of '%s' runtime", in.DebugEnv, DevModeAnnotation, runtime)
+	}
+	names := make(map[string]bool, len(in.Commands))
+	for _, command := range in.Commands {
+		// supervisord programs are passed to the supervisor init container as name:command:autostart entries separated by ;
+		// the commands which can't be passed as is being read from the env of the runtime container
+		if len(command.Name) == 0 || len(strings.TrimSpace(command.Command)) == 0 || strings.ContainsAny(command.Name, ":; ") {
+			return in, fmt.Errorf("invalid dev mode command '%s' in '%s' annotation of '%s' runtime: name and command are required and the name cannot contain spaces, ':' or ';'", command.Name, DevModeAnnotation, runtime)
+		}
+		if names[command.Name] {
+			return in, fmt.Errorf("duplicated dev mode command '%s' in '%s' annotation of '%s' runtime", command.Name, DevModeAnnotation, runtime)
+		}
+		names[command.Name] = true
+	}
+	if len(in.DebugProgram) == 0 {
+		in.DebugProgram = defaultDebugProgram(in.Commands)
+	} else if !names[in.DebugProgram] {
+		return in, fmt.Errorf("unknown debug program '%s' in '%s' annotation of '%s' runtime", in.DebugProgram, DevModeAnnotation, runtime)
+	}
+	for _, path := range in.MountPaths {
+		if !strings.HasPrefix(path, "/") {
+			return in, fmt.Errorf("dev mode mount path '%s' must be absolute in '%s' annotation of '%s' runtime", path, DevModeAnnotation, runtime)
+		}
+	}
+	return in, nil
+}
+
+// defaultDebugProgram returns the name of the program running the application among the specified ones: the run program
+// or, if there is none, the first program starting with the pod
+func defaultDebugProgram(commands []devModeCommand) string {
+	program := ""
+	for _, command := range commands {
+		if command.Name == "run" {
+			return command.Name
+		}
+		if command.Autostart && len(program) == 0 {
+			program = command.Name
+		}
+	}
+	return program
+}
+
+// devCommandEnvVarPrefix prefixes the env vars of the runtime container holding the commands of the supervisord programs
+// which are read from the env, suffixed by the index of the program
+const devCommandEnvVarPrefix = "HALKYON_DEV_COMMAND_"
+
+// readsCommandFromEnv checks whether the specified supervisord program reads its command from the env of the runtime
+// container rather than getting it from the supervisor init container, which is the case for commands containing ':' or
+// ';', which CMDS entries cannot, and for the debug program when debugging is enabled, so that commands are never escaped
+func (in devModeConfig) readsCommandFromEnv(i int) bool {
+	return strings.ContainsAny(in.Commands[i].Command, ":;") || (in.debugged && in.Commands[i].Name == in.DebugProgram)
+}
+
+// supervisedCommand returns the command the supervisor init container configures the specified supervisord program with.
+// Commands read from the env are evaluated by a shell, the debug program first appending the debug options to the env var
+// the runtime reads them from. The debug options contain ':' and are thus referenced rather than inlined.
+func (in devModeConfig) supervisedCommand(i int) string {
+	if !in.readsCommandFromEnv(i) {
+		return in.Commands[i].Command
+	}
+	envVar := devCommandEnvVarPrefix + strconv.Itoa(i)
+	if in.debugged && in.Commands[i].Name == in.DebugProgram {
+		return fmt.Sprintf(`/bin/sh -c 'export %[1]s="$%[1]s $%[2]s" && eval "$%[3]s"'`, in.DebugEnv, debugOptionsEnvVar, envVar)
+	}
+	return fmt.Sprintf(`/bin/sh -c 'eval "$%s"'`, envVar)
+}
+
+// supervisorCommands returns the value of the CMDS env var configuring the programs of the supervisor init container
+func (in devModeConfig) supervisorCommands() string {
+	commands := make([]string, 0, len(in.Commands))
+	for i, command := range in.Commands {
+		commands = append(commands, command.Name+":"+in.supervisedCommand(i)+":"+strconv.FormatBool(command.Autostart))
+	}
+	return strings.Join(commands, ";")
+}
+
+// addCommandEnvVars provides the runtime container with the commands of the supervisord programs reading them from its env
+func (in devModeConfig) addCommandEnvVars(envs map[string]corev1.EnvVar) {
+	for i, command := range in.Commands {
+		if in.readsCommandFromEnv(i) {
+			name := devCommandEnvVarPrefix + strconv.Itoa(i)
+			envs[name] = corev1.EnvVar{Name: name, Value: command.Command}
+		}
+	}
+}
+
+// supervisordBinary and supervisordConf return where the supervisord binary and its configuration are found in the
+// runtime container
+func (in devModeConfig) supervisordBinary() string {
+	return in.SupervisordDir + "/bin/supervisord"
+}
+
+func (in devModeConfig) supervisordConf() string {
+	return in.SupervisordDir + "/conf/supervisor.conf"
+}
+
+// DevModeTarget describes where the code pushed to a dev mode component is written and the supervisord programs building
+// and running it
+type DevModeTarget struct {
+	// Container is the name of the runtime container of the component's pods
+	Container string
+	// MountPaths are the paths where pushed files can be written
+	MountPaths []string
+	// Supervisord is the path of the supervisord binary controlling the programs
+	Supervisord string
+	// Programs tells, by name, whether each supervisord program runs along with the pod
+	Programs map[string]bool
+}
+
+// GetDevModeTarget returns where the code pushed to the specified dev mode component is written and how it is built and run
+func GetDevModeTarget(c *halkyon.Component) (DevModeTarget, error) {
+	if halkyon.BuildDeploymentMode == c.Spec.DeploymentMode {
+		return DevModeTarget{}, fmt.Errorf("'%s' component isn't in %s mode", c.Name, halkyon.DevDeploymentMode)
+	}
+	runtime, err := getImageInfo(c)
+	if err != nil {
+		return DevModeTarget{}, err
+	}
+	devMode := runtime.devMode
+	programs := make(map[string]bool, len(devMode.Commands))
+	for _, command := range devMode.Commands {
+		programs[command.Name] = command.Autostart
+	}
+	return DevModeTarget{
+		Container:   c.Name,
+		MountPaths:  devMode.MountPaths,
+		Supervisord: devMode.supervisordBinary(),
+		Programs:    programs,
+	}, nil
+}
+
+// LastPushAttributeKey records, on the pod condition, the outcome of the latest push received by the operator
+const LastPushAttributeKey = "LastPush"
+
+// PushOutcome describes the outcome of a push to a dev mode component, as recorded in its LastPushAnnotation
+type PushOutcome struct {
+	Time string `json:"time"`
+	// Path is the directory the files were written to
+	Path    string `json:"path"`
+	Files   int    `json:"files"`
+	Deleted int    `json:"deleted,omitempty"`
+	// Program is the supervisord program triggered once the files were written, if any
+	Program   string `json:"program,omitempty"`
+	Succeeded bool   `json:"succeeded"`
+	// Message holds the error or the end of the program output
+	Message string `json:"message,omitempty"`
+}
+
+// lastPush describes the outcome of the latest push to the specified component, an empty string if it didn't receive any
+func lastPush(c *halkyon.Component) string {
+	value, ok := c.Annotations[LastPushAnnotation]
+	if !ok {
+		return ""
+	}
+	outcome := PushOutcome{}
+	if err := json.Unmarshal([]byte(value), &outcome); err != nil {
+		return ""
+	}
+	result := "succeeded"
+	if !outcome.Succeeded {
+		result = "failed"
+	}
+	description := fmt.Sprintf("%d file(s) pushed to %s", outcome.Files, outcome.Path)
+	if outcome.Deleted > 0 {
+		description += fmt.Sprintf(", %d deleted", outcome.Deleted)
+	}
+	if len(outcome.Program) > 0 {
+		description += fmt.Sprintf(", '%s' program", outcome.Program)
+	}
+	description = fmt.Sprintf("%s at %s: %s", description, outcome.Time, result)
+	if !outcome.Succeeded && len(outcome.Message) > 0 {
+		description += ": " + outcome.Message
+	}
+	return description
+}
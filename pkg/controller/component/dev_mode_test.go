@@ -0,0 +1,101 @@
+package component
+
+import (
+	"halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"reflect"
+	"testing"
+)
+
+func TestDevModeDebugProgram(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   devModeConfig
+		expected string
+		valid    bool
+	}{
+		{name: "defaults", expected: "run", valid: true},
+		{name: "first autostarted", config: devModeConfig{Commands: []devModeCommand{{Name: "build", Command: "npm install"}, {Name: "start", Command: "npm start", Autostart: true}}}, expected: "start", valid: true},
+		{name: "specified", config: devModeConfig{Commands: []devModeCommand{{Name: "build", Command: "npm install"}, {Name: "dev", Command: "npm run dev"}}, DebugProgram: "dev"}, expected: "dev", valid: true},
+		{name: "unknown", config: devModeConfig{DebugProgram: "dev"}, valid: false},
+		{name: "quoted", config: devModeConfig{Commands: []devModeCommand{{Name: "run", Command: "sh -c 'npm start'"}}}, expected: "run", valid: true},
+		{name: "quarkus", config: devModeConfig{Commands: []devModeCommand{{Name: "dev", Command: "mvn quarkus:dev", Autostart: true}}}, expected: "dev", valid: true},
+		{name: "invalid name", config: devModeConfig{Commands: []devModeCommand{{Name: "run:dev", Command: "npm start"}}}, valid: false},
+		{name: "no command", config: devModeConfig{Commands: []devModeCommand{{Name: "run", Command: " "}}}, valid: false},
+		{name: "invalid env", config: devModeConfig{DebugEnv: "NODE OPTIONS"}, valid: false},
+	}
+	for _, c := range cases {
+		config, err := c.config.withDefaults("test")
+		if c.valid != (err == nil) {
+			t.Errorf("expected '%s' validity to be %t, got error: %v", c.name, c.valid, err)
+			continue
+		}
+		if c.valid && config.DebugProgram != c.expected {
+			t.Errorf("expected '%s' debug program for '%s', got '%s'", c.expected, c.name, config.DebugProgram)
+		}
+	}
+}
+
+func TestDevModeFor(t *testing.T) {
+	defer setRuntimes(springBootRuntime(nil))()
+	devMode, err := devModeConfig{}.withDefaults("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime := Runtime{devMode: devMode}
+	c := springBootComponent(v1beta1.DevDeploymentMode)
+	if commands := devModeFor(c, runtime).supervisorCommands(); commands != devMode.supervisorCommands() {
+		t.Errorf("expected commands to be kept when debugging is disabled, got %s", commands)
+	}
+
+	c.Annotations = map[string]string{DebugAnnotation: "{}"}
+	debugged := devModeFor(c, runtime)
+	expected := `build:/usr/local/bin/build:false;run:/bin/sh -c 'export JAVA_TOOL_OPTIONS="$JAVA_TOOL_OPTIONS $HALKYON_DEBUG_OPTIONS" && eval "$HALKYON_DEV_COMMAND_1"':true`
+	if commands := debugged.supervisorCommands(); commands != expected {
+		t.Errorf("expected only the run program to get the debug options, got %s", commands)
+	}
+	envs := map[string]corev1.EnvVar{}
+	debugged.addCommandEnvVars(envs)
+	if len(envs) != 1 || envs["HALKYON_DEV_COMMAND_1"].Value != "/usr/local/bin/run" {
+		t.Errorf("expected the command of the run program to be read from the env, got %+v", envs)
+	}
+	if defaultDevModeConfig.Commands[1].Command != "/usr/local/bin/run" {
+		t.Errorf("expected default commands not to be modified, got %+v", defaultDevModeConfig.Commands)
+	}
+}
+
+func TestSupervisorCommands(t *testing.T) {
+	devMode, err := devModeConfig{Commands: []devModeCommand{
+		{Name: "build", Command: "mvn package"},
+		{Name: "dev", Command: "mvn quarkus:dev", Autostart: true},
+		{Name: "reload", Command: "cd /usr/src; uvicorn main:app --reload"},
+	}}.withDefaults("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `build:mvn package:false;dev:/bin/sh -c 'eval "$HALKYON_DEV_COMMAND_1"':true;reload:/bin/sh -c 'eval "$HALKYON_DEV_COMMAND_2"':false`
+	if commands := devMode.supervisorCommands(); commands != expected {
+		t.Errorf("expected commands containing ':' or ';' to be read from the env, got %s", commands)
+	}
+	envs := map[string]corev1.EnvVar{}
+	devMode.addCommandEnvVars(envs)
+	expectedEnvs := map[string]corev1.EnvVar{
+		"HALKYON_DEV_COMMAND_1": {Name: "HALKYON_DEV_COMMAND_1", Value: "mvn quarkus:dev"},
+		"HALKYON_DEV_COMMAND_2": {Name: "HALKYON_DEV_COMMAND_2", Value: "cd /usr/src; uvicorn main:app --reload"},
+	}
+	if !reflect.DeepEqual(envs, expectedEnvs) {
+		t.Errorf("expected %+v env vars, got %+v", expectedEnvs, envs)
+	}
+}
+
+func TestGetImageInfoDevMode(t *testing.T) {
+	defer setRuntimes(springBootRuntime(map[string]string{DevModeAnnotation: `{"debugger":"gdb"}`}))()
+	c := springBootComponent(v1beta1.BuildDeploymentMode)
+	if _, err := getImageInfo(c); err != nil {
+		t.Errorf("expected invalid dev mode configuration to be ignored in build mode, got error: %v", err)
+	}
+	c.Spec.DeploymentMode = v1beta1.DevDeploymentMode
+	if _, err := getImageInfo(c); err == nil {
+		t.Errorf("expected invalid dev mode configuration to be reported in dev mode")
+	}
+}
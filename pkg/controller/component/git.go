@@ -0,0 +1,136 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	corev1 "k8s.io/api/core/v1"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// tektonGitAnnotationPrefix prefixes the annotations telling Tekton which git servers a secret holds credentials for
+const tektonGitAnnotationPrefix = "tekton.dev/git-"
+
+// gitConfig defines how the sources of a component are cloned
+type gitConfig struct {
+	// Secret is the name of a kubernetes.io/basic-auth or kubernetes.io/ssh-auth secret holding the git credentials
+	Secret string `json:"secret,omitempty"`
+	// Submodules specifies whether submodules are initialized and updated, which is the default
+	Submodules *bool `json:"submodules,omitempty"`
+	// Depth is the depth of the clone, 0 performing a full clone. Defaults to 1.
+	Depth *int `json:"depth,omitempty"`
+}
+
+// getGitConfig returns the git configuration of the specified component
+func getGitConfig(c *v1beta1.Component) (gitConfig, error) {
+	config := gitConfig{}
+	if _, err := unmarshalAnnotation(c, GitAnnotation, &config); err != nil {
+		return config, err
+	}
+	if config.Depth != nil && *config.Depth < 0 {
+		return config, fmt.Errorf("git clone depth must be positive, was %d", *config.Depth)
+	}
+	return config, nil
+}
+
+// resourceParams returns the parameters of the git PipelineResource which differ from Tekton's defaults
+func (g gitConfig) resourceParams() map[string]string {
+	params := make(map[string]string, 2)
+	if g.Submodules != nil {
+		params["submodules"] = strconv.FormatBool(*g.Submodules)
+	}
+	if g.Depth != nil {
+		params["depth"] = strconv.Itoa(*g.Depth)
+	}
+	return params
+}
+
+// annotateGitSecret makes sure that the git secret of the specified component, if any, is annotated so that Tekton uses
+// it to authenticate against the component's git server. The annotations of other servers are left untouched so that the
+// secret can be shared by components whose repositories live on different servers.
+func annotateGitSecret(c *v1beta1.Component) error {
+	config, err := getGitConfig(c)
+	if err != nil || len(config.Secret) == 0 {
+		return err
+	}
+	secret := &corev1.Secret{}
+	if _, err := framework.Helper.Fetch(config.Secret, c.Namespace, secret); err != nil {
+		return fmt.Errorf("couldn't retrieve '%s' git secret: %s", config.Secret, err.Error())
+	}
+
+	var server string
+	switch secret.Type {
+	case corev1.SecretTypeBasicAuth:
+		server, err = gitServerURL(c.Spec.BuildConfig.URL)
+	case corev1.SecretTypeSSHAuth:
+		server, err = gitServerHost(c.Spec.BuildConfig.URL)
+	default:
+		return fmt.Errorf("'%s' git secret must be of %s or %s type, was %s", config.Secret, corev1.SecretTypeBasicAuth, corev1.SecretTypeSSHAuth, secret.Type)
+	}
+	if err != nil {
+		return err
+	}
+	if !addGitServerAnnotation(secret, server) {
+		return nil
+	}
+	return framework.Helper.Client.Update(context.TODO(), secret)
+}
+
+// addGitServerAnnotation annotates the specified secret for Tekton to use it with the specified git server, using the first
+// free tekton.dev/git-* index, and returns whether the secret was modified, which isn't the case if it already was
+// annotated for this server
+func addGitServerAnnotation(secret *corev1.Secret, server string) bool {
+	used := make(map[string]bool, len(secret.Annotations))
+	for key, value := range secret.Annotations {
+		if strings.HasPrefix(key, tektonGitAnnotationPrefix) {
+			if value == server {
+				return false
+			}
+			used[key] = true
+		}
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string, 1)
+	}
+	for i := 0; ; i++ {
+		if key := tektonGitAnnotationPrefix + strconv.Itoa(i); !used[key] {
+			secret.Annotations[key] = server
+			return true
+		}
+	}
+}
+
+// gitServerURL returns the scheme and host of the specified HTTP(S) repository URL, as expected by Tekton for basic-auth
+// credentials
+func gitServerURL(repository string) (string, error) {
+	parsed, err := url.Parse(repository)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", fmt.Errorf("basic-auth git credentials require a HTTP(S) repository URL, was '%s'", repository)
+	}
+	return parsed.Scheme + "://" + parsed.Host, nil
+}
+
+// gitServerHost returns the host of the specified SSH repository URL, either using the ssh:// or scp-like syntax, as
+// expected by Tekton for ssh-auth credentials
+func gitServerHost(repository string) (string, error) {
+	if strings.Contains(repository, "://") {
+		parsed, err := url.Parse(repository)
+		if err != nil || parsed.Scheme != "ssh" {
+			return "", fmt.Errorf("ssh-auth git credentials require a SSH repository URL, was '%s'", repository)
+		}
+		return parsed.Host, nil
+	}
+	// scp-like syntax: [user@]host:path
+	colon := strings.IndexRune(repository, ':')
+	if colon <= 0 {
+		return "", fmt.Errorf("ssh-auth git credentials require a SSH repository URL, was '%s'", repository)
+	}
+	host := repository[:colon]
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	return host, nil
+}
@@ -0,0 +1,31 @@
+package component
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+)
+
+func TestAddGitServerAnnotation(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		modified    bool
+		expected    map[string]string
+	}{
+		{name: "not annotated", annotations: nil, modified: true, expected: map[string]string{"tekton.dev/git-0": "https://github.com"}},
+		{name: "already annotated", annotations: map[string]string{"tekton.dev/git-0": "https://github.com"}, modified: false, expected: map[string]string{"tekton.dev/git-0": "https://github.com"}},
+		{name: "other server", annotations: map[string]string{"tekton.dev/git-0": "https://gitlab.com"}, modified: true, expected: map[string]string{"tekton.dev/git-0": "https://gitlab.com", "tekton.dev/git-1": "https://github.com"}},
+		{name: "gap", annotations: map[string]string{"tekton.dev/git-1": "https://gitlab.com", "owner": "team"}, modified: true, expected: map[string]string{"tekton.dev/git-0": "https://github.com", "tekton.dev/git-1": "https://gitlab.com", "owner": "team"}},
+	}
+	for _, c := range cases {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}}
+		if modified := addGitServerAnnotation(secret, "https://github.com"); modified != c.modified {
+			t.Errorf("expected '%s' secret modification to be %t, got %t", c.name, c.modified, modified)
+		}
+		if !reflect.DeepEqual(secret.Annotations, c.expected) {
+			t.Errorf("expected %v annotations for '%s' secret, got %v", c.expected, c.name, secret.Annotations)
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package component
 
 import (
+	"fmt"
 	component "halkyon.io/api/component/v1beta1"
 	"halkyon.io/api/v1beta1"
 	"halkyon.io/operator-framework"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"os"
+	"reflect"
+	"sort"
 )
 
 const (
@@ -12,39 +17,150 @@ const (
 	BaseS2iImage          = "BASE_S2I_IMAGE"
 )
 
-func getEnvAsMap(component *component.Component) (map[string]string, error) {
-	envs := component.Spec.Envs
-	tmpEnvVar := make(map[string]string)
-
-	for _, v := range envs {
-		tmpEnvVar[v.Name] = v.Value
+func getEnvAsMap(component *component.Component) (map[string]corev1.EnvVar, error) {
+	image, err := getImageInfo(component)
+	if err != nil {
+		return map[string]corev1.EnvVar{}, err
 	}
 
-	image, err := getImageInfo(component)
+	refs, err := getEnvRefs(component)
 	if err != nil {
-		return map[string]string{}, err
+		return map[string]corev1.EnvVar{}, err
+	}
+
+	return mergeEnvVars(component.Spec.Envs, refs, image.defaultEnv), nil
+}
+
+// getEnvRefs returns the env vars referencing their value from another source, as specified by the EnvRefsAnnotation
+func getEnvRefs(component *component.Component) ([]corev1.EnvVar, error) {
+	var refs []corev1.EnvVar
+	if _, err := unmarshalAnnotation(component, EnvRefsAnnotation, &refs); err != nil {
+		return nil, err
+	}
+	for i, ref := range refs {
+		if len(ref.Name) == 0 {
+			return nil, fmt.Errorf("env vars specified in '%s' annotation must have a name", EnvRefsAnnotation)
+		}
+		if ref.ValueFrom == nil || len(ref.Value) > 0 {
+			return nil, fmt.Errorf("'%s' env var specified in '%s' annotation must only use valueFrom, use envs for plain values", ref.Name, EnvRefsAnnotation)
+		}
+		for _, env := range component.Spec.Envs {
+			if env.Name == ref.Name {
+				return nil, fmt.Errorf("'%s' env var is specified both in envs and in '%s' annotation", ref.Name, EnvRefsAnnotation)
+			}
+		}
+		refs[i].ValueFrom = withEnvVarSourceDefaults(ref.ValueFrom)
+	}
+	return refs, nil
+}
+
+// withEnvVarSourceDefaults returns a copy of the specified valueFrom reference with the defaults the API server applies,
+// so that references omitting them compare equal to the ones read back from the cluster
+func withEnvVarSourceDefaults(source *corev1.EnvVarSource) *corev1.EnvVarSource {
+	if source == nil {
+		return nil
+	}
+	defaulted := source.DeepCopy()
+	if defaulted.FieldRef != nil && len(defaulted.FieldRef.APIVersion) == 0 {
+		defaulted.FieldRef.APIVersion = "v1"
+	}
+	if defaulted.ResourceFieldRef != nil && defaulted.ResourceFieldRef.Divisor.IsZero() {
+		defaulted.ResourceFieldRef.Divisor = resource.MustParse("0")
+	}
+	return defaulted
+}
+
+// sameEnvVarSource checks whether both valueFrom references are the same once defaulted, divisors being compared by value
+func sameEnvVarSource(wanted, existing *corev1.EnvVarSource) bool {
+	wanted, existing = withEnvVarSourceDefaults(wanted), withEnvVarSourceDefaults(existing)
+	if wanted == nil || existing == nil {
+		return wanted == existing
+	}
+	if wanted.ResourceFieldRef != nil && existing.ResourceFieldRef != nil {
+		if wanted.ResourceFieldRef.Divisor.Cmp(existing.ResourceFieldRef.Divisor) != 0 {
+			return false
+		}
+		wanted.ResourceFieldRef.Divisor, existing.ResourceFieldRef.Divisor = resource.Quantity{}, resource.Quantity{}
+	}
+	return reflect.DeepEqual(wanted, existing)
+}
+
+// mergeEnvVars combines the specified env vars and valueFrom references with the runtime's defaults, the specified
+// values taking precedence
+func mergeEnvVars(envs []v1beta1.NameValuePair, refs []corev1.EnvVar, defaults map[string]string) map[string]corev1.EnvVar {
+	tmpEnvVar := make(map[string]corev1.EnvVar, len(envs)+len(refs)+len(defaults))
+	for _, v := range envs {
+		tmpEnvVar[v.Name] = corev1.EnvVar{Name: v.Name, Value: v.Value}
+	}
+	for _, ref := range refs {
+		tmpEnvVar[ref.Name] = ref
 	}
 
 	// Check if Component EnvVar contains the defaults
-	for k, v := range image.defaultEnv {
+	for k, v := range defaults {
 		if _, ok := tmpEnvVar[k]; !ok {
-			tmpEnvVar[k] = v
+			tmpEnvVar[k] = corev1.EnvVar{Name: k, Value: v}
 		}
 	}
 
-	return tmpEnvVar, nil
+	return tmpEnvVar
 }
 
-func populateEnvVar(component *component.Component) error {
+// getEnvVars returns the env vars to use for the specified component, sorted by name so that rendering them is stable
+func getEnvVars(component *component.Component) ([]corev1.EnvVar, error) {
 	tmpEnvVar, err := getEnvAsMap(component)
+	if err != nil {
+		return nil, err
+	}
+	return sortedEnvVars(tmpEnvVar), nil
+}
+
+// sortedEnvVars converts the specified map to a slice of env vars sorted by name
+func sortedEnvVars(envs map[string]corev1.EnvVar) []corev1.EnvVar {
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sorted := make([]corev1.EnvVar, 0, len(names))
+	for _, name := range names {
+		sorted = append(sorted, envs[name])
+	}
+	return sorted
+}
+
+// sameEnvVars checks whether both slices define the same env vars, taking valueFrom references into account but
+// disregarding ordering so that existing resources don't get needlessly rewritten
+func sameEnvVars(wanted, existing []corev1.EnvVar) bool {
+	if len(wanted) != len(existing) {
+		return false
+	}
+	existingAsMap := make(map[string]corev1.EnvVar, len(existing))
+	for _, envVar := range existing {
+		existingAsMap[envVar.Name] = envVar
+	}
+	for _, envVar := range wanted {
+		found, ok := existingAsMap[envVar.Name]
+		if !ok || found.Value != envVar.Value || !sameEnvVarSource(envVar.ValueFrom, found.ValueFrom) {
+			return false
+		}
+	}
+	return true
+}
+
+func populateEnvVar(component *component.Component) error {
+	envVars, err := getEnvVars(component)
 	if err != nil {
 		return err
 	}
 
-	// Convert Map to Slice
-	newEnvVars := make([]v1beta1.NameValuePair, 0, len(tmpEnvVar))
-	for k, v := range tmpEnvVar {
-		newEnvVars = append(newEnvVars, v1beta1.NameValuePair{Name: k, Value: v})
+	// Convert to name/value pairs, keeping the sorted order, env vars using valueFrom cannot be represented this way
+	newEnvVars := make([]v1beta1.NameValuePair, 0, len(envVars))
+	for _, envVar := range envVars {
+		if envVar.ValueFrom == nil {
+			newEnvVars = append(newEnvVars, v1beta1.NameValuePair{Name: envVar.Name, Value: envVar.Value})
+		}
 	}
 
 	// Store result
@@ -54,7 +170,11 @@ func populateEnvVar(component *component.Component) error {
 
 //getAppLabels returns a string map with the Application labels which will be associated to the kubernetes/ocp resource created and managed by this operator
 func getAppLabels(component *component.Component) map[string]string {
-	name := component.DeploymentName()
+	return appLabelsFor(component.DeploymentName())
+}
+
+// appLabelsFor returns the Application labels identifying the pods of the deployment with the specified name
+func appLabelsFor(name string) map[string]string {
 	return map[string]string{
 		"app":          name,
 		"component_cr": name,
@@ -112,7 +232,8 @@ func moduleDirName(c *component.Component) string {
 	}
 }
 
-func gitRevision(c *component.Component) string {
+// GitRevision returns the git reference to build the specified component from, defaulting to master
+func GitRevision(c *component.Component) string {
 	if c.Spec.BuildConfig.Ref == "" {
 		return "master"
 	} else {
@@ -0,0 +1,140 @@
+package component
+
+import (
+	"encoding/json"
+	"halkyon.io/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"testing"
+)
+
+func TestEnvVarsRenderingIsStable(t *testing.T) {
+	envs := []v1beta1.NameValuePair{
+		{Name: "SPRING_PROFILES_ACTIVE", Value: "kubernetes"},
+		{Name: "JAVA_APP_DIR", Value: "/deployments"},
+		{Name: "MAVEN_ARGS", Value: "-DskipTests"},
+	}
+	defaults := map[string]string{
+		"JARPATTERN":   "*",
+		"JAVA_APP_DIR": "/tmp",
+		"DEBUG":        "false",
+		"ZZZ":          "last",
+		"AAA":          "first",
+	}
+
+	first, err := json.Marshal(sortedEnvVars(mergeEnvVars(envs, nil, defaults)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		rendered, err := json.Marshal(sortedEnvVars(mergeEnvVars(envs, nil, defaults)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(first) != string(rendered) {
+			t.Fatalf("rendering env vars should be stable, got:\n%s\nthen:\n%s", first, rendered)
+		}
+	}
+}
+
+func TestEnvVarsAreSortedAndSpecifiedValuesWin(t *testing.T) {
+	envs := []v1beta1.NameValuePair{{Name: "B", Value: "specified"}}
+	defaults := map[string]string{"C": "c", "B": "default", "A": "a"}
+
+	rendered := sortedEnvVars(mergeEnvVars(envs, nil, defaults))
+	expected := []corev1.EnvVar{{Name: "A", Value: "a"}, {Name: "B", Value: "specified"}, {Name: "C", Value: "c"}}
+	if len(rendered) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, rendered)
+	}
+	for i, envVar := range expected {
+		if rendered[i] != envVar {
+			t.Errorf("expected %v at index %d, got %v", envVar, i, rendered[i])
+		}
+	}
+}
+
+func TestEnvVarsKeepValueFromReferences(t *testing.T) {
+	envs := []v1beta1.NameValuePair{{Name: "PROFILE", Value: "kubernetes"}}
+	refs := []corev1.EnvVar{
+		{Name: "PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "db-config"},
+			Key:                  "password",
+		}}},
+		{Name: "POD_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+		{Name: "JARPATTERN", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"},
+			Key:                  "pattern",
+		}}},
+	}
+	defaults := map[string]string{"JARPATTERN": "*"}
+
+	rendered := sortedEnvVars(mergeEnvVars(envs, refs, defaults))
+	if len(rendered) != 4 {
+		t.Fatalf("expected 4 env vars, got %v", rendered)
+	}
+	for _, envVar := range rendered {
+		switch envVar.Name {
+		case "PASSWORD":
+			if envVar.ValueFrom == nil || envVar.ValueFrom.SecretKeyRef == nil || envVar.ValueFrom.SecretKeyRef.Key != "password" {
+				t.Errorf("secret reference was lost: %v", envVar)
+			}
+		case "POD_IP":
+			if envVar.ValueFrom == nil || envVar.ValueFrom.FieldRef == nil {
+				t.Errorf("field reference was lost: %v", envVar)
+			}
+		case "JARPATTERN":
+			if envVar.ValueFrom == nil || envVar.ValueFrom.ConfigMapKeyRef == nil || len(envVar.Value) > 0 {
+				t.Errorf("config map reference should take precedence over runtime default: %v", envVar)
+			}
+		}
+	}
+}
+
+func TestSameEnvVars(t *testing.T) {
+	secretRef := &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "db-config"},
+		Key:                  "password",
+	}}
+	wanted := []corev1.EnvVar{{Name: "A", Value: "a"}, {Name: "PASSWORD", ValueFrom: secretRef}}
+
+	reordered := []corev1.EnvVar{{Name: "PASSWORD", ValueFrom: secretRef}, {Name: "A", Value: "a"}}
+	if !sameEnvVars(wanted, reordered) {
+		t.Error("ordering shouldn't matter when comparing env vars")
+	}
+
+	flattened := []corev1.EnvVar{{Name: "A", Value: "a"}, {Name: "PASSWORD"}}
+	if sameEnvVars(wanted, flattened) {
+		t.Error("env var losing its valueFrom reference should be considered different")
+	}
+
+	otherKey := []corev1.EnvVar{{Name: "A", Value: "a"}, {Name: "PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "db-config"},
+		Key:                  "user",
+	}}}}
+	if sameEnvVars(wanted, otherKey) {
+		t.Error("env var referencing another key should be considered different")
+	}
+
+	if sameEnvVars(wanted, wanted[:1]) {
+		t.Error("missing env var should be considered different")
+	}
+}
+
+func TestSameEnvVarsAppliesServerDefaults(t *testing.T) {
+	wanted := []corev1.EnvVar{
+		{Name: "POD_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+		{Name: "LIMIT", ValueFrom: &corev1.EnvVarSource{ResourceFieldRef: &corev1.ResourceFieldSelector{Resource: "limits.memory"}}},
+	}
+	existing := []corev1.EnvVar{
+		{Name: "POD_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "status.podIP"}}},
+		{Name: "LIMIT", ValueFrom: &corev1.EnvVarSource{ResourceFieldRef: &corev1.ResourceFieldSelector{Resource: "limits.memory", Divisor: resource.MustParse("0")}}},
+	}
+	if !sameEnvVars(wanted, existing) {
+		t.Error("references omitting fields defaulted by the API server should be considered the same")
+	}
+
+	existing[1].ValueFrom.ResourceFieldRef.Divisor = resource.MustParse("1Mi")
+	if sameEnvVars(wanted, existing) {
+		t.Error("references with different divisors should be considered different")
+	}
+}
@@ -0,0 +1,81 @@
+package component
+
+import (
+	"halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator-framework"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type horizontalPodAutoscaler struct {
+	base
+}
+
+var _ framework.DependentResource = &horizontalPodAutoscaler{}
+var hpaGVK = autoscalingv2beta1.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler")
+
+func newHorizontalPodAutoscaler(owner *v1beta1.Component) horizontalPodAutoscaler {
+	config := framework.NewConfig(hpaGVK)
+	// configuration errors are reported when building the deployment
+	scaling, _ := getScalingConfig(owner)
+	config.Created = scaling.Autoscaling != nil
+	config.Updated = config.Created
+	h := horizontalPodAutoscaler{base: newConfiguredBaseDependent(owner, config)}
+	h.NameFn = h.Name
+	return h
+}
+
+func (res horizontalPodAutoscaler) Build(empty bool) (runtime.Object, error) {
+	hpa := &autoscalingv2beta1.HorizontalPodAutoscaler{}
+	if !empty {
+		c := res.ownerAsComponent()
+		scaling, err := getScalingConfig(c)
+		if err != nil {
+			return nil, err
+		}
+		hpa.ObjectMeta = metav1.ObjectMeta{
+			Name:      res.Name(),
+			Namespace: c.Namespace,
+			Labels:    getAppLabels(c),
+		}
+		hpa.Spec = res.spec(scaling.Autoscaling)
+	}
+	return hpa, nil
+}
+
+func (res horizontalPodAutoscaler) spec(autoscaling *autoscalingConfig) autoscalingv2beta1.HorizontalPodAutoscalerSpec {
+	return autoscalingv2beta1.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv2beta1.CrossVersionObjectReference{
+			APIVersion: deploymentGVK.GroupVersion().String(),
+			Kind:       deploymentGVK.Kind,
+			Name:       res.ownerAsComponent().DeploymentName(),
+		},
+		MinReplicas: autoscaling.MinReplicas,
+		MaxReplicas: autoscaling.MaxReplicas,
+		Metrics:     autoscaling.metrics(),
+	}
+}
+
+func (res horizontalPodAutoscaler) Name() string {
+	return res.ownerAsComponent().DeploymentName()
+}
+
+func (res horizontalPodAutoscaler) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	hpa := toUpdate.(*autoscalingv2beta1.HorizontalPodAutoscaler)
+	scaling, err := getScalingConfig(res.ownerAsComponent())
+	if err != nil || scaling.Autoscaling == nil {
+		return false, hpa, err
+	}
+	wanted := res.spec(scaling.Autoscaling)
+	if wanted.MinReplicas == nil {
+		// let the API server default apply
+		wanted.MinReplicas = hpa.Spec.MinReplicas
+	}
+	if !equality.Semantic.DeepEqual(wanted, hpa.Spec) {
+		hpa.Spec = wanted
+		return true, hpa, nil
+	}
+	return false, hpa, nil
+}
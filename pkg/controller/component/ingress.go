@@ -32,20 +32,27 @@ func (res ingress) Build(empty bool) (runtime.Object, error) {
 			Namespace: c.Namespace,
 			Labels:    ls,
 		}
-		ingress.Spec = v1beta1.IngressSpec{
-			Rules: []v1beta1.IngressRule{
-				{Host: c.Name,
-					IngressRuleValue: v1beta1.IngressRuleValue{
-						HTTP: &v1beta1.HTTPIngressRuleValue{
-							Paths: []v1beta1.HTTPIngressPath{
-								{
-									Path: "/",
-									Backend: v1beta1.IngressBackend{
-										ServiceName: c.Name,
-										ServicePort: intstr.IntOrString{
-											Type:   intstr.Int,
-											IntVal: c.Spec.Port,
-										},
+		ingress.Spec = ingressSpecFor(c, c.Name)
+	}
+
+	return ingress, nil
+}
+
+// ingressSpecFor returns the spec of an ingress exposing the specified component's host through the named service
+func ingressSpecFor(c *v1beta12.Component, serviceName string) v1beta1.IngressSpec {
+	return v1beta1.IngressSpec{
+		Rules: []v1beta1.IngressRule{
+			{Host: c.Name,
+				IngressRuleValue: v1beta1.IngressRuleValue{
+					HTTP: &v1beta1.HTTPIngressRuleValue{
+						Paths: []v1beta1.HTTPIngressPath{
+							{
+								Path: "/",
+								Backend: v1beta1.IngressBackend{
+									ServiceName: serviceName,
+									ServicePort: intstr.IntOrString{
+										Type:   intstr.Int,
+										IntVal: c.Spec.Port,
 									},
 								},
 							},
@@ -53,8 +60,6 @@ func (res ingress) Build(empty bool) (runtime.Object, error) {
 					},
 				},
 			},
-		}
+		},
 	}
-
-	return ingress, nil
 }
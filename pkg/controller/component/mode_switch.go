@@ -0,0 +1,118 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	halkyon "halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ModeSwitchAttributeKey records, on the service condition, the step a switch between deployment modes is at
+const ModeSwitchAttributeKey = "ModeSwitch"
+
+// Steps of a switch between deployment modes: the deployment of the new mode is created while the service keeps routing
+// the traffic to the deployment of the previous mode until the new one is available, the previous deployment being
+// removed once the service was switched
+const (
+	modeSwitchWaitingForDeployment = "WaitingForDeployment"
+	modeSwitchRemovingPrevious     = "RemovingPreviousDeployment"
+)
+
+// previousModeDeploymentName returns the name of the deployment used by the specified component in the mode it isn't in
+func previousModeDeploymentName(c *halkyon.Component) string {
+	previous := halkyon.DevDeploymentMode
+	if halkyon.DevDeploymentMode == c.Spec.DeploymentMode {
+		previous = halkyon.BuildDeploymentMode
+	}
+	return c.DeploymentNameFor(previous)
+}
+
+// fetchDeployment retrieves the deployment with the specified name, returning nil if it doesn't exist
+func fetchDeployment(name, namespace string) (*appsv1.Deployment, error) {
+	d := &appsv1.Deployment{}
+	if _, err := framework.Helper.Fetch(name, namespace, d); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// isDeploymentAvailable checks whether the specified deployment rolled out its current spec and has available pods
+func isDeploymentAvailable(d *appsv1.Deployment) bool {
+	return d != nil && d.Status.ObservedGeneration >= d.Generation && d.Status.AvailableReplicas > 0
+}
+
+// canSwitchServiceTo checks whether a service currently routing traffic to the specified deployment can be switched to
+// the deployment of the component's current mode: either the new deployment is available or there is no traffic to
+// preserve since the currently targeted deployment doesn't exist
+func canSwitchServiceTo(c *halkyon.Component, current string) (bool, error) {
+	previous, err := fetchDeployment(current, c.Namespace)
+	if err != nil || previous == nil {
+		return err == nil, err
+	}
+	next, err := fetchDeployment(c.DeploymentName(), c.Namespace)
+	if err != nil {
+		return false, err
+	}
+	return canSwitchBetween(previous, next), nil
+}
+
+// canSwitchBetween checks whether traffic routed to the previous deployment, nil if it doesn't exist, can be routed to
+// the next one instead
+func canSwitchBetween(previous, next *appsv1.Deployment) bool {
+	return previous == nil || isDeploymentAvailable(next)
+}
+
+// fetchPreviousModeDeployment retrieves the deployment of the mode the specified component isn't in, returning nil if it
+// doesn't exist or isn't controlled by the component, so that a deployment with the same name isn't mistaken for it
+func fetchPreviousModeDeployment(c *halkyon.Component) (*appsv1.Deployment, error) {
+	previous, err := fetchDeployment(previousModeDeploymentName(c), c.Namespace)
+	if err != nil || previous == nil || !metav1.IsControlledBy(previous, c) {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// modeSwitchStep returns the step the switch of the specified component to its current mode is at given the deployment
+// its service routes the traffic to and the deployment of the previous mode, nil if there is none, an empty step meaning
+// that no switch is in progress
+func modeSwitchStep(c *halkyon.Component, current string, previous *appsv1.Deployment) string {
+	if current != c.DeploymentName() {
+		return modeSwitchWaitingForDeployment
+	}
+	if previous != nil {
+		return modeSwitchRemovingPrevious
+	}
+	return ""
+}
+
+// removePreviousModeDeployment deletes the deployment of the mode the specified component switched from once its service
+// routes the traffic to the deployment of the current mode
+func removePreviousModeDeployment(c *halkyon.Component) error {
+	svc := &corev1.Service{}
+	if _, err := framework.Helper.Fetch(framework.DefaultDependentResourceNameFor(c), c.Namespace, svc); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if svc.Spec.Selector["app"] != c.DeploymentName() {
+		return nil
+	}
+	// only delete the previous deployment if it still exists, since this is checked on every reconciliation
+	previous, err := fetchPreviousModeDeployment(c)
+	if err != nil || previous == nil || previous.DeletionTimestamp != nil {
+		return err
+	}
+	if err := framework.Helper.Client.Delete(context.TODO(), previous, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("couldn't remove '%s' deployment after switching to %s mode: %s", previous.Name, c.Spec.DeploymentMode, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package component
+
+import (
+	halkyon "halkyon.io/api/component/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestPreviousModeDeploymentName(t *testing.T) {
+	c := &halkyon.Component{ObjectMeta: metav1.ObjectMeta{Name: "fruits"}}
+	c.Spec.DeploymentMode = halkyon.BuildDeploymentMode
+	if name := previousModeDeploymentName(c); name != c.DeploymentNameFor(halkyon.DevDeploymentMode) {
+		t.Errorf("expected dev mode deployment to be the previous one in build mode, got '%s'", name)
+	}
+	c.Spec.DeploymentMode = halkyon.DevDeploymentMode
+	if name := previousModeDeploymentName(c); name != c.DeploymentNameFor(halkyon.BuildDeploymentMode) {
+		t.Errorf("expected build mode deployment to be the previous one in dev mode, got '%s'", name)
+	}
+}
+
+func TestCanSwitchServiceTo(t *testing.T) {
+	deployment := func(generation, observed int64, available int32) *appsv1.Deployment {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "fruits-build", Generation: generation}}
+		d.Status.ObservedGeneration = observed
+		d.Status.AvailableReplicas = available
+		return d
+	}
+	previous := deployment(1, 1, 1)
+	cases := []struct {
+		name      string
+		previous  *appsv1.Deployment
+		next      *appsv1.Deployment
+		canSwitch bool
+	}{
+		{name: "no traffic to preserve", next: nil, canSwitch: true},
+		{name: "next not created yet", previous: previous, next: nil},
+		{name: "next not rolled out", previous: previous, next: deployment(2, 1, 1)},
+		{name: "next without available pods", previous: previous, next: deployment(1, 1, 0)},
+		{name: "next available", previous: previous, next: deployment(2, 2, 1), canSwitch: true},
+	}
+	for _, c := range cases {
+		if canSwitch := canSwitchBetween(c.previous, c.next); canSwitch != c.canSwitch {
+			t.Errorf("%s: expected service switch to be possible: %t, got %t", c.name, c.canSwitch, canSwitch)
+		}
+	}
+}
+
+func TestModeSwitchStep(t *testing.T) {
+	c := &halkyon.Component{ObjectMeta: metav1.ObjectMeta{Name: "fruits"}}
+	c.Spec.DeploymentMode = halkyon.BuildDeploymentMode
+	previous := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: previousModeDeploymentName(c)}}
+	cases := []struct {
+		name     string
+		current  string
+		previous *appsv1.Deployment
+		step     string
+	}{
+		{name: "waiting for deployment", current: previousModeDeploymentName(c), previous: previous, step: modeSwitchWaitingForDeployment},
+		{name: "removing previous deployment", current: c.DeploymentName(), previous: previous, step: modeSwitchRemovingPrevious},
+		{name: "switched", current: c.DeploymentName()},
+	}
+	for _, tc := range cases {
+		if step := modeSwitchStep(c, tc.current, tc.previous); step != tc.step {
+			t.Errorf("%s: expected '%s' step, got '%s'", tc.name, tc.step, step)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package component
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	halkyon "halkyon.io/api/component/v1beta1"
-	framework "halkyon.io/operator-framework"
+	"strings"
 )
 
 func PVCName(c *halkyon.Component) string {
@@ -13,10 +16,55 @@ func PVCName(c *halkyon.Component) string {
 	return "m2-data-" + c.Name // todo: use better default name?
 }
 
-func ServiceAccountName(owner framework.SerializableResource) string {
-	return "build-bot"
+// ServiceAccountName returns the name of the service account the builds of the specified component run with, rootless
+// builds, as well as dev mode components, using a dedicated one which isn't granted the privileged SCC
+func ServiceAccountName(c *halkyon.Component) string {
+	if needsPrivilegedBuilds(c) {
+		return privilegedBuildServiceAccount
+	}
+	return rootlessBuildServiceAccount
+}
+
+// TaskName returns the name of the Task building the specified component's image. Each component gets its own Task so
+// that components sharing a namespace don't compete over its ownership and content.
+func TaskName(c *halkyon.Component) string {
+	strategy, err := buildStrategyFor(c)
+	if err != nil {
+		// unknown build types are reported when building the Task, fall back to the default one for naming purposes
+		strategy = buildStrategies[defaultBuildStrategy]
+	}
+	return c.Name + "-" + strategy.taskName()
+}
+
+// PipelineName returns the name of the Pipeline building the specified component when it opted into a build pipeline
+func PipelineName(c *halkyon.Component) string {
+	return c.Name + "-pipeline"
 }
 
-func TaskName(owner framework.SerializableResource) string {
-	return "s2i-buildah-push"
+// CandidateName returns the name of the deployment, and of its service, running the new image of the specified component
+// while it is being rolled out progressively
+func CandidateName(c *halkyon.Component) string {
+	return c.DeploymentNameFor(halkyon.BuildDeploymentMode) + "-candidate"
+}
+
+// BuildName returns the name of the TaskRun, or PipelineRun, building the specified component. The name is derived from
+// the inputs of the build so that a new build is triggered whenever one of them changes.
+func BuildName(c *halkyon.Component) string {
+	inputs := []string{
+		buildStrategyName(c),
+		c.Spec.BuildConfig.URL,
+		GitRevision(c),
+		contextPath(c),
+		moduleDirName(c),
+		c.Spec.BuildConfig.BaseImage,
+		c.Annotations[BuildTriggerAnnotation],
+	}
+	if dockerfile, ok := c.Annotations[DockerfileAnnotation]; ok {
+		inputs = append(inputs, dockerfile)
+	}
+	if stages, ok := c.Annotations[PipelineAnnotation]; ok {
+		inputs = append(inputs, stages)
+	}
+	hash := sha256.Sum256([]byte(strings.Join(inputs, "\n")))
+	return fmt.Sprintf("%s-build-%s", c.Name, hex.EncodeToString(hash[:])[:10])
 }
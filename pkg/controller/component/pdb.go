@@ -0,0 +1,106 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator-framework"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+type podDisruptionBudget struct {
+	base
+}
+
+var _ framework.DependentResource = &podDisruptionBudget{}
+var pdbGVK = policyv1beta1.SchemeGroupVersion.WithKind("PodDisruptionBudget")
+
+func newPodDisruptionBudget(owner *v1beta1.Component) podDisruptionBudget {
+	config := framework.NewConfig(pdbGVK)
+	// configuration errors are reported when building the deployment
+	scaling, _ := getScalingConfig(owner)
+	config.Created = scaling.isScaled()
+	config.Updated = config.Created
+	p := podDisruptionBudget{base: newConfiguredBaseDependent(owner, config)}
+	p.NameFn = p.Name
+	return p
+}
+
+func (res podDisruptionBudget) Build(empty bool) (runtime.Object, error) {
+	pdb := &policyv1beta1.PodDisruptionBudget{}
+	if !empty {
+		c := res.ownerAsComponent()
+		scaling, err := getScalingConfig(c)
+		if err != nil {
+			return nil, err
+		}
+		ls := getAppLabels(c)
+		pdb.ObjectMeta = metav1.ObjectMeta{
+			Name:      res.Name(),
+			Namespace: c.Namespace,
+			Labels:    ls,
+		}
+		pdb.Spec = policyv1beta1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: ls},
+		}
+		setDisruptionBudget(&pdb.Spec, scaling)
+	}
+	return pdb, nil
+}
+
+// setDisruptionBudget sets the budget as configured, defaulting to allowing one unavailable pod at a time
+func setDisruptionBudget(spec *policyv1beta1.PodDisruptionBudgetSpec, scaling scalingConfig) {
+	spec.MinAvailable = scaling.MinAvailable
+	spec.MaxUnavailable = scaling.MaxUnavailable
+	if spec.MinAvailable == nil && spec.MaxUnavailable == nil {
+		maxUnavailable := intstr.FromInt(1)
+		spec.MaxUnavailable = &maxUnavailable
+	}
+}
+
+func (res podDisruptionBudget) Name() string {
+	return res.ownerAsComponent().DeploymentName()
+}
+
+// Update recreates the budget when its minAvailable or maxUnavailable changed: the spec of policy/v1beta1 budgets can't be
+// updated before Kubernetes 1.15
+func (res podDisruptionBudget) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	pdb := toUpdate.(*policyv1beta1.PodDisruptionBudget)
+	c := res.ownerAsComponent()
+	scaling, err := getScalingConfig(c)
+	if err != nil {
+		return false, pdb, err
+	}
+	wanted := pdb.Spec.DeepCopy()
+	setDisruptionBudget(wanted, scaling)
+	if sameDisruptionBudget(*wanted, pdb.Spec) {
+		return false, pdb, nil
+	}
+	if err := framework.Helper.Client.Delete(context.TODO(), pdb, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		return false, pdb, fmt.Errorf("couldn't delete '%s' PodDisruptionBudget to recreate it: %s", pdb.Name, err.Error())
+	}
+	built, err := res.Build(false)
+	if err != nil {
+		return false, pdb, err
+	}
+	recreated := built.(*policyv1beta1.PodDisruptionBudget)
+	if err := controllerutil.SetControllerReference(c, recreated, framework.Helper.Scheme); err != nil {
+		return false, pdb, err
+	}
+	if err := framework.Helper.Client.Create(context.TODO(), recreated); err != nil && !errors.IsAlreadyExists(err) {
+		return false, pdb, fmt.Errorf("couldn't recreate '%s' PodDisruptionBudget: %s", pdb.Name, err.Error())
+	}
+	return false, recreated, nil
+}
+
+// sameDisruptionBudget returns whether the specified budget specs allow the same disruptions
+func sameDisruptionBudget(wanted, existing policyv1beta1.PodDisruptionBudgetSpec) bool {
+	return equality.Semantic.DeepEqual(wanted.MinAvailable, existing.MinAvailable) && equality.Semantic.DeepEqual(wanted.MaxUnavailable, existing.MaxUnavailable)
+}
@@ -0,0 +1,394 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	tektonv1beta1 "halkyon.io/operator/pkg/apis/tekton/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+)
+
+const (
+	// buildStage is the pipeline stage running the component's build Task
+	buildStage = "build"
+	// imageDigestParam is the parameter through which stages working on the built image get its digest
+	imageDigestParam = "imageDigest"
+	trivyImage       = "aquasec/trivy:0.18.3"
+	cosignImage      = "gcr.io/projectsigstore/cosign:v1.13.1"
+	signingKeyVolume = "signing-key"
+	signingKeyDir    = "/etc/signing-key"
+	// defaultMavenTestImage and mavenTestCommand run the Maven tests of the component's module by default
+	defaultMavenTestImage = "maven:3.6-jdk-11"
+	mavenTestCommand      = `mvn -B -pl "$MODULE" -am -Dmaven.repo.local=` + mavenRepositoryPath + ` test`
+)
+
+// mavenBuildStrategies are the build types building Maven projects, whose tests are run by default by the test stage
+var mavenBuildStrategies = map[string]bool{"s2i": true, "jib": true}
+
+// pipelineConfig defines the stages of the pipeline building a component
+type pipelineConfig struct {
+	// Stages lists the stages of the pipeline in the order they run, which must include the build stage
+	Stages []string `json:"stages"`
+	// TestImage is the image the test stage runs the tests with and TestCommand the shell command running them. They
+	// default to running the Maven tests of the component's module with maven:3.6-jdk-11 for the build types building
+	// Maven projects and must be specified for the other ones.
+	TestImage   string `json:"testImage,omitempty"`
+	TestCommand string `json:"testCommand,omitempty"`
+	// ScanSeverity is the comma-separated list of vulnerability severities making the scan stage fail, defaults to
+	// CRITICAL,HIGH
+	ScanSeverity string `json:"scanSeverity,omitempty"`
+	// SigningSecret is the name of the secret holding the cosign.key private key and its cosign.password, as generated by
+	// cosign generate-key-pair, the sign stage signs images with. Defaults to cosign.
+	SigningSecret string `json:"signingSecret,omitempty"`
+}
+
+// pipelineStage is a stage of the catalog pipelines are made of, in addition to the build stage
+type pipelineStage struct {
+	// usesImage indicates that the stage works on the image pushed by the build stage, getting its digest as the
+	// imageDigest parameter, rather than on the sources
+	usesImage bool
+	// taskSpec returns the specification of the Task run by the stage, written like build strategies' ones
+	taskSpec func(c *v1beta1.Component, config pipelineConfig) v1alpha1.TaskSpec
+}
+
+var pipelineStages = map[string]pipelineStage{
+	"test": {taskSpec: testStageSpec},
+	"scan": {usesImage: true, taskSpec: scanStageSpec},
+	"sign": {usesImage: true, taskSpec: signStageSpec},
+}
+
+// getPipelineConfig returns the pipeline configuration of the specified component, returning false if the component
+// isn't built by a pipeline
+func getPipelineConfig(c *v1beta1.Component) (pipelineConfig, bool, error) {
+	config := pipelineConfig{
+		ScanSeverity:  "CRITICAL,HIGH",
+		SigningSecret: "cosign",
+	}
+	configured, err := unmarshalAnnotation(c, PipelineAnnotation, &config)
+	if err != nil || !configured {
+		return config, false, err
+	}
+	built := false
+	seen := make(map[string]bool, len(config.Stages))
+	for _, name := range config.Stages {
+		if seen[name] {
+			return config, true, fmt.Errorf("'%s' stage is specified more than once in '%s' annotation", name, PipelineAnnotation)
+		}
+		seen[name] = true
+		if name == buildStage {
+			built = true
+			continue
+		}
+		stage, ok := pipelineStages[name]
+		if !ok {
+			known := make([]string, 0, len(pipelineStages)+1)
+			for n := range pipelineStages {
+				known = append(known, n)
+			}
+			known = append(known, buildStage)
+			sort.Strings(known)
+			return config, true, fmt.Errorf("unknown '%s' pipeline stage, known stages: %s", name, strings.Join(known, ","))
+		}
+		if stage.usesImage && !built {
+			return config, true, fmt.Errorf("'%s' stage works on the built image and must come after the '%s' stage", name, buildStage)
+		}
+	}
+	if !built {
+		return config, true, fmt.Errorf("'%s' annotation must include the '%s' stage", PipelineAnnotation, buildStage)
+	}
+	if seen["test"] && len(config.TestCommand) == 0 {
+		if !mavenBuildStrategies[buildStrategyName(c)] {
+			return config, true, fmt.Errorf("'test' stage of components using the '%s' build type requires testImage and testCommand in '%s' annotation", buildStrategyName(c), PipelineAnnotation)
+		}
+		config.TestCommand = mavenTestCommand
+		if len(config.TestImage) == 0 {
+			config.TestImage = defaultMavenTestImage
+		}
+	}
+	if seen["test"] && len(config.TestImage) == 0 {
+		return config, true, fmt.Errorf("'test' stage requires testImage in '%s' annotation when testCommand is specified", PipelineAnnotation)
+	}
+	// components built by a pipeline only run once their image is pinned by the digest the build reports
+	if strategy, err := buildStrategyFor(c); err == nil && !reportsDigest(strategy.taskSpec()) {
+		return config, true, fmt.Errorf("build pipelines need the digest of the built image, which the '%s' build type doesn't report", buildStrategyName(c))
+	}
+	if usesJobExecutor() || !usesTektonV1beta1() {
+		return config, true, fmt.Errorf("build pipelines require the %s Tekton API", tektonv1beta1.SchemeGroupVersion)
+	}
+	return config, true, nil
+}
+
+// usesPipeline checks whether the specified component is built by a pipeline, invalid configurations being reported
+// when validating the component
+func usesPipeline(c *v1beta1.Component) bool {
+	_, pipelined, err := getPipelineConfig(c)
+	return pipelined && err == nil
+}
+
+// removeUnusedPipeline deletes the Pipeline and PipelineRuns of the specified component once it doesn't opt into a build
+// pipeline anymore, since dependents which aren't created aren't deleted either and builds then switch back to TaskRuns,
+// leaving the PipelineRuns out of the pruned build history
+func removeUnusedPipeline(c *v1beta1.Component) error {
+	if _, pipelined, _ := getPipelineConfig(c); pipelined || !isTektonAvailable() || !usesTektonV1beta1() {
+		return nil
+	}
+	p := &tektonv1beta1.Pipeline{}
+	if _, err := framework.Helper.Fetch(PipelineName(c), c.Namespace, p); err == nil {
+		if metav1.IsControlledBy(p, c) {
+			if err := framework.Helper.Client.Delete(context.TODO(), p); err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("couldn't remove unused '%s' Pipeline: %s", p.Name, err.Error())
+			}
+		}
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	lo := &client.ListOptions{}
+	lo.InNamespace(c.Namespace)
+	lo.MatchingLabels(getBuildLabels(c.Name))
+	runs := &tektonv1beta1.PipelineRunList{}
+	if err := framework.Helper.Client.List(context.TODO(), lo, runs); err != nil {
+		return err
+	}
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		if !metav1.IsControlledBy(run, c) {
+			continue
+		}
+		if err := framework.Helper.Client.Delete(context.TODO(), run, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("couldn't remove unused '%s' PipelineRun: %s", run.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+type pipeline struct {
+	base
+}
+
+var _ framework.DependentResource = &pipeline{}
+
+func newPipeline(owner *v1beta1.Component) pipeline {
+	config := framework.NewConfig(tektonGroupVersion().WithKind("Pipeline"))
+	config.Watched = isTektonAvailable()
+	config.CheckedForReadiness = v1beta1.BuildDeploymentMode == owner.Spec.DeploymentMode && usesPipeline(owner)
+	config.Created = config.CheckedForReadiness
+	config.Updated = config.CheckedForReadiness
+	p := pipeline{base: newConfiguredBaseDependent(owner, config)}
+	p.NameFn = p.Name
+	return p
+}
+
+func (res pipeline) Name() string {
+	return PipelineName(res.ownerAsComponent())
+}
+
+func (res pipeline) Build(empty bool) (runtime.Object, error) {
+	if empty {
+		if usesTektonV1beta1() {
+			return &tektonv1beta1.Pipeline{}, nil
+		}
+		return &v1alpha1.Pipeline{}, nil
+	}
+	c := res.ownerAsComponent()
+	spec, err := pipelineSpecFor(c)
+	if err != nil {
+		return nil, err
+	}
+	return &tektonv1beta1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: c.Namespace,
+			Name:      res.Name(),
+			Labels:    getBuildLabels(c.Name),
+		},
+		Spec: spec,
+	}, nil
+}
+
+func (res pipeline) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	p, ok := toUpdate.(*tektonv1beta1.Pipeline)
+	if !ok {
+		return false, toUpdate, nil
+	}
+	wanted, err := pipelineSpecFor(res.ownerAsComponent())
+	if err != nil {
+		return false, toUpdate, err
+	}
+	if !equality.Semantic.DeepEqual(wanted, p.Spec) {
+		p.Spec = wanted
+		return true, p, nil
+	}
+	return false, toUpdate, nil
+}
+
+// pipelineSpecFor returns the spec of the Pipeline building the specified component: its stages run in the configured
+// order, each one only starting once the previous one succeeded. The build stage references the component's build Task
+// while the other stages embed theirs. All the parameters of the stages are exposed as Pipeline parameters, except for
+// the image digest which is taken from the build stage results.
+func pipelineSpecFor(c *v1beta1.Component) (tektonv1beta1.PipelineSpec, error) {
+	config, _, err := getPipelineConfig(c)
+	if err != nil {
+		return tektonv1beta1.PipelineSpec{}, err
+	}
+	spec := tektonv1beta1.PipelineSpec{
+		Workspaces: []tektonv1beta1.PipelineWorkspaceDeclaration{{Name: sourceWorkspace}},
+		Tasks:      make([]tektonv1beta1.PipelineTask, 0, len(config.Stages)),
+	}
+	declared := make(map[string]bool, 20)
+	for i, name := range config.Stages {
+		task := tektonv1beta1.PipelineTask{Name: name}
+		var taskSpec tektonv1beta1.TaskSpec
+		if name == buildStage {
+			build, err := taskSpecFor(c)
+			if err != nil {
+				return spec, err
+			}
+			taskSpec = toV1beta1TaskSpec(build)
+			task.TaskRef = &v1alpha1.TaskRef{Name: TaskName(c)}
+		} else {
+			stage, err := stageTaskSpecFor(c, config, name)
+			if err != nil {
+				return spec, err
+			}
+			taskSpec = toV1beta1TaskSpec(stage)
+			task.TaskSpec = &taskSpec
+		}
+		if i > 0 {
+			task.RunAfter = []string{config.Stages[i-1]}
+		}
+		for _, param := range taskSpec.Params {
+			value := "$(params." + param.Name + ")"
+			if param.Name == imageDigestParam {
+				value = "$(tasks." + buildStage + ".results." + digestResult + ")"
+			} else if !declared[param.Name] {
+				declared[param.Name] = true
+				spec.Params = append(spec.Params, param)
+			}
+			task.Params = append(task.Params, stringParam(param.Name, value))
+		}
+		for _, workspace := range taskSpec.Workspaces {
+			task.Workspaces = append(task.Workspaces, tektonv1beta1.WorkspacePipelineTaskBinding{Name: workspace.Name, Workspace: sourceWorkspace})
+		}
+		spec.Tasks = append(spec.Tasks, task)
+	}
+	return spec, nil
+}
+
+// stageTaskSpecFor returns the spec of the Task run by the specified stage for the given component, configured to access
+// its registry and use its build cache
+func stageTaskSpecFor(c *v1beta1.Component, config pipelineConfig, name string) (v1alpha1.TaskSpec, error) {
+	registry, err := getRegistryConfig(c)
+	if err != nil {
+		return v1alpha1.TaskSpec{}, err
+	}
+	cache, cached, err := getBuildCacheConfig(c)
+	if err != nil {
+		return v1alpha1.TaskSpec{}, err
+	}
+	spec := pipelineStages[name].taskSpec(c, config)
+	registry.configure(&spec, dockerImageURL(c))
+	if cached {
+		cache.configure(&spec, cache.name(c))
+	}
+	return spec, nil
+}
+
+// testStageSpec runs the configured test command in the component's context path, the Maven repository being kept along
+// with the build cache, if any
+func testStageSpec(c *v1beta1.Component, config pipelineConfig) v1alpha1.TaskSpec {
+	spec := buildTaskSpec(
+		stringParamSpec("testImage", config.TestImage, "The image to run the tests with"),
+		stringParamSpec("testCommand", config.TestCommand, "The shell command running the tests"),
+		stringParamSpec("testModule", moduleDirName(c), "The name of the directory containing the module to test"),
+	)
+	spec.Outputs = nil
+	spec.Steps = []v1alpha1.Step{
+		{Container: corev1.Container{
+			Name:       "test",
+			Image:      "$(inputs.params.testImage)",
+			WorkingDir: "$(inputs.params.workspacePath)/$(inputs.params.contextPath)",
+			Command:    []string{"/bin/sh", "-c"},
+			Args:       []string{"$(inputs.params.testCommand)"},
+			Env: []corev1.EnvVar{
+				{Name: "MODULE", Value: "$(inputs.params.testModule)"},
+			},
+			VolumeMounts: []corev1.VolumeMount{mavenRepositoryMount},
+		}},
+	}
+	spec.Volumes = []corev1.Volume{mavenRepositoryVolumeFor()}
+	return spec
+}
+
+// scanStageSpec scans the built image for vulnerabilities using Trivy, failing if some of the configured severities are
+// found
+func scanStageSpec(_ *v1beta1.Component, config pipelineConfig) v1alpha1.TaskSpec {
+	return v1alpha1.TaskSpec{
+		Inputs: &v1alpha1.Inputs{
+			Params: []v1alpha1.ParamSpec{
+				stringParamSpec("verifyTLS", "true", "Verify registry certificates"),
+				stringParamSpec("scanSeverity", config.ScanSeverity, "The vulnerability severities making the scan fail"),
+				{Name: imageDigestParam, Type: v1alpha1.ParamTypeString, Description: "The digest of the image to scan"},
+			},
+		},
+		Steps: []v1alpha1.Step{
+			{Container: corev1.Container{
+				Name:    "scan",
+				Image:   trivyImage,
+				Command: []string{"/bin/sh", "-c"},
+				Args: []string{`if [ "$VERIFY_TLS" = "false" ]; then export TRIVY_INSECURE=true; fi; ` +
+					`trivy image --no-progress --exit-code 1 --severity "$SEVERITY" "$IMAGE@$DIGEST"`},
+				Env: []corev1.EnvVar{
+					{Name: "VERIFY_TLS", Value: "$(inputs.params.verifyTLS)"},
+					{Name: "SEVERITY", Value: "$(inputs.params.scanSeverity)"},
+					{Name: "IMAGE", Value: "$(outputs.resources.image.url)"},
+					{Name: "DIGEST", Value: "$(inputs.params." + imageDigestParam + ")"},
+				},
+			}},
+		},
+	}
+}
+
+// signStageSpec signs the built image with cosign, pushing the signature to the component's registry
+func signStageSpec(_ *v1beta1.Component, config pipelineConfig) v1alpha1.TaskSpec {
+	optional := true
+	return v1alpha1.TaskSpec{
+		Inputs: &v1alpha1.Inputs{
+			Params: []v1alpha1.ParamSpec{
+				{Name: imageDigestParam, Type: v1alpha1.ParamTypeString, Description: "The digest of the image to sign"},
+			},
+		},
+		Steps: []v1alpha1.Step{
+			{Container: corev1.Container{
+				Name:    "sign",
+				Image:   cosignImage,
+				Command: []string{"cosign"},
+				Args:    []string{"sign", "--key", signingKeyDir + "/cosign.key", "$(outputs.resources.image.url)@$(inputs.params." + imageDigestParam + ")"},
+				Env: []corev1.EnvVar{{
+					Name: "COSIGN_PASSWORD",
+					ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: config.SigningSecret},
+						Key:                  "cosign.password",
+						Optional:             &optional,
+					}},
+				}},
+				VolumeMounts: []corev1.VolumeMount{{Name: signingKeyVolume, MountPath: signingKeyDir, ReadOnly: true}},
+			}},
+		},
+		Volumes: []corev1.Volume{{
+			Name: signingKeyVolume,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName: config.SigningSecret,
+				Items:      []corev1.KeyToPath{{Key: "cosign.key", Path: "cosign.key"}},
+			}},
+		}},
+	}
+}
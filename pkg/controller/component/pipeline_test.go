@@ -0,0 +1,156 @@
+package component
+
+import (
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	tektonv1beta1 "halkyon.io/operator/pkg/apis/tekton/v1beta1"
+	"reflect"
+	"testing"
+)
+
+// setTektonV1beta1 makes Tekton available with the v1beta1 API if the specified value is true, the v1alpha1 one otherwise
+func setTektonV1beta1(beta bool) {
+	setTektonAvailable(true)
+	if beta {
+		tektonAPI.version = tektonv1beta1.SchemeGroupVersion
+	}
+}
+
+func pipelinedComponent(buildType, pipeline string) *v1beta1.Component {
+	c := buildComponent(map[string]string{PipelineAnnotation: pipeline})
+	c.Spec.BuildConfig.Type = buildType
+	return c
+}
+
+func TestGetPipelineConfig(t *testing.T) {
+	defer setTektonV1beta1(false)
+	cases := []struct {
+		name        string
+		buildType   string
+		pipeline    string
+		v1alpha1    bool
+		testCommand string
+		valid       bool
+	}{
+		{name: "build only", pipeline: `{"stages":["build"]}`, valid: true},
+		{name: "all stages", pipeline: `{"stages":["test","build","scan","sign"]}`, testCommand: mavenTestCommand, valid: true},
+		{name: "jib tests", buildType: "jib", pipeline: `{"stages":["test","build"]}`, testCommand: mavenTestCommand, valid: true},
+		{name: "custom tests", buildType: "dockerfile", pipeline: `{"stages":["test","build"],"testImage":"node:12","testCommand":"npm test"}`, testCommand: "npm test", valid: true},
+		{name: "no test command", buildType: "dockerfile", pipeline: `{"stages":["test","build"]}`, valid: false},
+		{name: "no test image", buildType: "buildpacks", pipeline: `{"stages":["test","build"],"testCommand":"npm test"}`, valid: false},
+		{name: "duplicated stage", pipeline: `{"stages":["build","build"]}`, valid: false},
+		{name: "unknown stage", pipeline: `{"stages":["build","deploy"]}`, valid: false},
+		{name: "scan before build", pipeline: `{"stages":["scan","build"]}`, valid: false},
+		{name: "sign before build", pipeline: `{"stages":["test","sign","build"]}`, valid: false},
+		{name: "no build", pipeline: `{"stages":["test"]}`, valid: false},
+		{name: "v1alpha1", pipeline: `{"stages":["build"]}`, v1alpha1: true, valid: false},
+	}
+	for _, c := range cases {
+		setTektonV1beta1(!c.v1alpha1)
+		config, pipelined, err := getPipelineConfig(pipelinedComponent(c.buildType, c.pipeline))
+		if !pipelined {
+			t.Errorf("%s: expected component to be built by a pipeline", c.name)
+		}
+		if c.valid != (err == nil) {
+			t.Errorf("%s: expected validity to be %t, got error: %v", c.name, c.valid, err)
+			continue
+		}
+		if c.valid && config.TestCommand != c.testCommand {
+			t.Errorf("%s: expected '%s' test command, got '%s'", c.name, c.testCommand, config.TestCommand)
+		}
+	}
+
+	if _, pipelined, err := getPipelineConfig(buildComponent(nil)); pipelined || err != nil {
+		t.Errorf("expected component without pipeline annotation not to be built by a pipeline, got %t and error: %v", pipelined, err)
+	}
+}
+
+func TestPipelineSpecFor(t *testing.T) {
+	defer setTektonV1beta1(false)
+	setTektonV1beta1(true)
+	c := pipelinedComponent("", `{"stages":["test","build","scan","sign"]}`)
+	spec, err := pipelineSpecFor(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := make(map[string]tektonv1beta1.PipelineTask, len(spec.Tasks))
+	names := make([]string, 0, len(spec.Tasks))
+	for _, task := range spec.Tasks {
+		tasks[task.Name] = task
+		names = append(names, task.Name)
+	}
+	if expected := []string{"test", "build", "scan", "sign"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v tasks, got %v", expected, names)
+	}
+	// each stage only starts once the previous one succeeded
+	for i, task := range spec.Tasks {
+		var expected []string
+		if i > 0 {
+			expected = []string{spec.Tasks[i-1].Name}
+		}
+		if !reflect.DeepEqual(task.RunAfter, expected) {
+			t.Errorf("expected '%s' task to run after %v, got %v", task.Name, expected, task.RunAfter)
+		}
+	}
+
+	build := tasks[buildStage]
+	if build.TaskRef == nil || build.TaskRef.Name != TaskName(c) || build.TaskSpec != nil {
+		t.Errorf("expected build stage to reference '%s' Task, got %+v", TaskName(c), build)
+	}
+	for _, name := range []string{"test", "scan", "sign"} {
+		if task := tasks[name]; task.TaskRef != nil || task.TaskSpec == nil {
+			t.Errorf("expected '%s' stage to embed its Task, got %+v", name, task)
+		}
+	}
+
+	// stages working on the sources share the source workspace
+	for _, name := range []string{"test", "build"} {
+		if workspaces := tasks[name].Workspaces; len(workspaces) != 1 || workspaces[0].Workspace != sourceWorkspace {
+			t.Errorf("expected '%s' stage to use the '%s' workspace, got %+v", name, sourceWorkspace, workspaces)
+		}
+	}
+	if workspaces := tasks["scan"].Workspaces; len(workspaces) != 0 {
+		t.Errorf("expected scan stage not to use the sources, got %+v workspaces", workspaces)
+	}
+
+	// the stages working on the image get its digest from the build results
+	digest := "$(tasks." + buildStage + ".results." + digestResult + ")"
+	for _, name := range []string{"scan", "sign"} {
+		if value := taskParam(tasks[name], imageDigestParam); value != digest {
+			t.Errorf("expected '%s' stage to get the digest from the build results, got '%s'", name, value)
+		}
+	}
+	if value := taskParam(tasks["test"], "testCommand"); value != "$(params.testCommand)" {
+		t.Errorf("expected test stage to get its command from the Pipeline params, got '%s'", value)
+	}
+
+	// parameters shared by stages are only declared once and the digest isn't a Pipeline parameter
+	declared := make(map[string]v1alpha1.ParamSpec, len(spec.Params))
+	for _, param := range spec.Params {
+		if _, ok := declared[param.Name]; ok {
+			t.Errorf("'%s' param is declared more than once", param.Name)
+		}
+		declared[param.Name] = param
+	}
+	if _, ok := declared[imageDigestParam]; ok {
+		t.Errorf("expected '%s' not to be a Pipeline param", imageDigestParam)
+	}
+	for _, name := range []string{"gitUrl", "image", "contextPath", "testImage", "scanSeverity"} {
+		if _, ok := declared[name]; !ok {
+			t.Errorf("expected '%s' Pipeline param, got %v", name, paramNames(spec.Params))
+		}
+	}
+	if command := declared["testCommand"]; command.Default == nil || command.Default.StringVal != mavenTestCommand {
+		t.Errorf("expected Maven tests to run by default, got %+v", command.Default)
+	}
+}
+
+func taskParam(task tektonv1beta1.PipelineTask, name string) string {
+	for _, param := range task.Params {
+		if param.Name == name {
+			return param.Value.StringVal
+		}
+	}
+	return ""
+}
@@ -9,6 +9,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
 	"strings"
 )
 
@@ -20,7 +21,7 @@ var _ framework.DependentResource = &pod{}
 
 func newPod(owner *v1beta1.Component) pod {
 	config := framework.NewConfig(v1beta1.PodGVK)
-	config.CheckedForReadiness = v1beta1.DevDeploymentMode == owner.Spec.DeploymentMode
+	config.CheckedForReadiness = true
 	config.Created = false
 	return pod{base: newConfiguredBaseDependent(owner, config)}
 }
@@ -35,63 +36,110 @@ func (res pod) Build(empty bool) (runtime.Object, error) {
 
 func (res pod) GetCondition(underlying runtime.Object, err error) *beta1.DependentCondition {
 	return framework.DefaultCustomizedGetConditionFor(res, err, underlying, func(underlying runtime.Object, cond *beta1.DependentCondition) {
-		p := underlying.(*corev1.Pod)
-		msg := ""
-		ready := true
-		for _, c := range p.Status.Conditions {
-			if c.Type == corev1.PodReady {
-				if c.Status != corev1.ConditionTrue {
-					ready = false
-					if "ContainersNotReady" == c.Reason {
-						// extract list of not ready containers
-						openBracket := strings.IndexRune(c.Message, '[')
-						var notReadyContainers []string
-						if openBracket > 1 {
-							containerList := c.Message[openBracket+1 : strings.IndexRune(c.Message, ']')]
-							notReadyContainers = strings.Split(containerList, ",")
-						}
-						msgArr := make([]string, 0, len(notReadyContainers))
-						for _, c := range notReadyContainers {
-							for _, status := range p.Status.ContainerStatuses {
-								waiting := status.State.Waiting
-								if status.Name == c && waiting != nil {
-									format := "%s: %s"
-									waitMsg := waiting.Message
-									var m string
-									if len(waitMsg) > 0 {
-										format = format + " => %s"
-										m = fmt.Sprintf(format, c, waiting.Reason, waitMsg)
-									} else {
-										m = fmt.Sprintf(format, c, waiting.Reason)
-									}
+		pods := underlying.(*corev1.PodList).Items
+		readyCount := 0
+		worstSeverity := -1
+		worstMsg := ""
+		for i := range pods {
+			p := &pods[i]
+			ready, msg, severity := podReadiness(p)
+			if ready {
+				// record the first ready pod so that clients know where to push their code to
+				if readyCount == 0 {
+					cond.SetAttribute(v1beta1.PodNameAttributeKey, p.Name)
+				}
+				readyCount++
+			} else if severity > worstSeverity {
+				worstSeverity = severity
+				worstMsg = msg
+			}
+		}
+
+		if push := lastPush(res.ownerAsComponent()); len(push) > 0 {
+			cond.SetAttribute(LastPushAttributeKey, push)
+		}
+
+		total := len(pods)
+		if readyCount == total {
+			cond.Type = beta1.DependentReady
+			cond.Reason = beta1.ReasonReady
+			if total == 1 {
+				cond.Message = fmt.Sprintf("%s is ready", pods[0].Name)
+			} else {
+				cond.Message = fmt.Sprintf("%d/%d pods are ready", readyCount, total)
+			}
+			return
+		}
+		cond.Type = beta1.DependentPending
+		cond.Reason = beta1.ReasonPending
+		cond.Message = fmt.Sprintf("%d/%d pods are ready: %s", readyCount, total, worstMsg)
+	})
+}
+
+const (
+	podNotReady = iota
+	podWaitingOnError
+	podFailed
+)
+
+// podReadiness checks whether the specified pod is ready, explaining why if not along with how severe the issue is
+func podReadiness(p *corev1.Pod) (ready bool, msg string, severity int) {
+	ready = true
+	severity = podNotReady
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			if c.Status != corev1.ConditionTrue {
+				ready = false
+				if "ContainersNotReady" == c.Reason {
+					// extract list of not ready containers
+					openBracket := strings.IndexRune(c.Message, '[')
+					var notReadyContainers []string
+					if openBracket > 1 {
+						containerList := c.Message[openBracket+1 : strings.IndexRune(c.Message, ']')]
+						notReadyContainers = strings.FieldsFunc(containerList, func(r rune) bool { return r == ',' || r == ' ' })
+					}
+					msgArr := make([]string, 0, len(notReadyContainers))
+					for _, c := range notReadyContainers {
+						for _, status := range p.Status.ContainerStatuses {
+							if status.Name == c {
+								if m := containerNotReadyMessage(p, status); len(m) > 0 {
 									msgArr = append(msgArr, m)
 								}
+								if waiting := status.State.Waiting; waiting != nil && isErrorWaitingReason(waiting.Reason) {
+									severity = podWaitingOnError
+								}
 							}
 						}
-						msg = strings.Join(msgArr, " & ")
-					} else {
-						msg = c.Message
 					}
-					msg = fmt.Sprintf("%s pod is not ready: %s => %s", p.Name, c.Reason, msg)
+					msg = strings.Join(msgArr, " & ")
 				} else {
-					msg = fmt.Sprintf("%s is ready", p.Name)
-					cond.SetAttribute(v1beta1.PodNameAttributeKey, p.Name)
+					msg = c.Message
 				}
-				break
+				msg = fmt.Sprintf("%s pod is not ready: %s => %s", p.Name, c.Reason, msg)
+			} else {
+				msg = fmt.Sprintf("%s is ready", p.Name)
 			}
+			break
 		}
-		if len(p.Status.Message) > 0 {
-			msg = p.Status.Message + ": " + msg
-		}
-		cond.Message = msg
-		cond.Type = beta1.DependentPending
-		cond.Reason = beta1.ReasonPending
-		if ready {
-			cond.Type = beta1.DependentReady
-			cond.Reason = beta1.ReasonReady
-		}
+	}
+	if len(p.Status.Message) > 0 {
+		msg = p.Status.Message + ": " + msg
+	}
+	if p.Status.Phase == corev1.PodFailed {
+		ready = false
+		severity = podFailed
+	}
+	return ready, msg, severity
+}
 
-	})
+// isErrorWaitingReason checks whether a container waiting for the specified reason is unlikely to recover on its own
+func isErrorWaitingReason(reason string) bool {
+	switch reason {
+	case "CrashLoopBackOff", "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError":
+		return true
+	default:
+		return false
+	}
 }
 
 func (res pod) Fetch() (runtime.Object, error) {
@@ -99,16 +147,51 @@ func (res pod) Fetch() (runtime.Object, error) {
 	lo := &client.ListOptions{}
 	component := res.ownerAsComponent()
 	lo.InNamespace(component.Namespace)
-	lo.MatchingLabels(map[string]string{"app": component.Name})
+	lo.MatchingLabels(map[string]string{"app": getAppLabels(component)["app"]})
 	if err := framework.Helper.Client.List(context.TODO(), lo, pods); err != nil {
 		return nil, err
-	} else {
-		// We assume that there is only one Pod containing the label app=component name AND we return it
-		if len(pods.Items) > 0 {
-			return &pods.Items[0], nil
-		} else {
-			err := fmt.Errorf("failed to get pod created for the component")
-			return nil, err
+	}
+
+	// only consider pods that are not being terminated, sorted by name to get a stable result
+	active := make([]corev1.Pod, 0, len(pods.Items))
+	for _, p := range pods.Items {
+		if p.DeletionTimestamp == nil {
+			active = append(active, p)
+		}
+	}
+	if len(active) == 0 {
+		return nil, fmt.Errorf("failed to get pod created for the component")
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].Name < active[j].Name
+	})
+	pods.Items = active
+	return pods, nil
+}
+
+// containerNotReadyMessage explains why the container associated with the specified status is not ready, identifying the
+// failing probe if the container is running
+func containerNotReadyMessage(p *corev1.Pod, status corev1.ContainerStatus) string {
+	name := status.Name
+	if waiting := status.State.Waiting; waiting != nil {
+		if len(waiting.Message) > 0 {
+			return fmt.Sprintf("%s: %s => %s", name, waiting.Reason, waiting.Message)
+		}
+		return fmt.Sprintf("%s: %s", name, waiting.Reason)
+	}
+
+	if status.State.Running == nil {
+		return ""
+	}
+	for _, container := range p.Spec.Containers {
+		if container.Name == name {
+			if status.RestartCount > 0 && status.LastTerminationState.Terminated != nil && container.LivenessProbe != nil {
+				return fmt.Sprintf("%s: liveness probe (%s) is failing, restarted %d time(s)", name, describeProbe(container.LivenessProbe), status.RestartCount)
+			}
+			if container.ReadinessProbe != nil {
+				return fmt.Sprintf("%s: readiness probe (%s) is failing", name, describeProbe(container.ReadinessProbe))
+			}
 		}
 	}
+	return ""
 }
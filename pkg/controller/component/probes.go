@@ -0,0 +1,109 @@
+package component
+
+import (
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// probesConfig defines the probes to set on a component's runtime container
+type probesConfig struct {
+	Liveness  *corev1.Probe `json:"liveness,omitempty"`
+	Readiness *corev1.Probe `json:"readiness,omitempty"`
+	// Startup is emulated by delaying the liveness probe since the targeted Kubernetes API doesn't support startup probes
+	Startup *corev1.Probe `json:"startup,omitempty"`
+}
+
+// getProbes computes the probes to use for the specified component, probes specified on the component overriding the
+// runtime's defaults. Runtime defaults are only used in build mode since, in dev mode, the application is not started
+// until code is pushed.
+func getProbes(component *v1beta1.Component) (probesConfig, error) {
+	probes := probesConfig{}
+	if v1beta1.BuildDeploymentMode == component.Spec.DeploymentMode {
+		runtime, err := getImageInfo(component)
+		if err != nil {
+			return probes, err
+		}
+		probes = runtime.probes
+	}
+	specified := probesConfig{}
+	if _, err := unmarshalAnnotation(component, ProbesAnnotation, &specified); err != nil {
+		return probes, err
+	}
+	if specified.Liveness != nil {
+		probes.Liveness = specified.Liveness
+	}
+	if specified.Readiness != nil {
+		probes.Readiness = specified.Readiness
+	}
+	if specified.Startup != nil {
+		probes.Startup = specified.Startup
+	}
+	return probes, nil
+}
+
+// setProbes sets the probes on the specified container, probes without explicit port targeting the component's port
+func setProbes(container *corev1.Container, probes probesConfig, port int32) {
+	container.ReadinessProbe = completedProbe(probes.Readiness, port)
+	container.LivenessProbe = completedProbe(probes.Liveness, port)
+	startup := completedProbe(probes.Startup, port)
+	if container.LivenessProbe != nil && startup != nil {
+		// give the application as much time to start as the startup probe would have before liveness is checked
+		grace := startup.InitialDelaySeconds + startup.PeriodSeconds*startup.FailureThreshold
+		if grace > container.LivenessProbe.InitialDelaySeconds {
+			container.LivenessProbe.InitialDelaySeconds = grace
+		}
+	}
+}
+
+// completedProbe returns a copy of the specified probe with its port and the values Kubernetes would otherwise default
+// explicitly set so that the resulting probe can be compared to the one retrieved from the cluster
+func completedProbe(probe *corev1.Probe, port int32) *corev1.Probe {
+	if probe == nil {
+		return nil
+	}
+	p := probe.DeepCopy()
+	defaultPort := intstr.FromInt(int(port))
+	if p.HTTPGet != nil {
+		if p.HTTPGet.Port.IntValue() == 0 && p.HTTPGet.Port.Type == intstr.Int {
+			p.HTTPGet.Port = defaultPort
+		}
+		if len(p.HTTPGet.Scheme) == 0 {
+			p.HTTPGet.Scheme = corev1.URISchemeHTTP
+		}
+		if len(p.HTTPGet.Path) == 0 {
+			p.HTTPGet.Path = "/"
+		}
+	}
+	if p.TCPSocket != nil && p.TCPSocket.Port.IntValue() == 0 && p.TCPSocket.Port.Type == intstr.Int {
+		p.TCPSocket.Port = defaultPort
+	}
+	if p.TimeoutSeconds == 0 {
+		p.TimeoutSeconds = 1
+	}
+	if p.PeriodSeconds == 0 {
+		p.PeriodSeconds = 10
+	}
+	if p.SuccessThreshold == 0 {
+		p.SuccessThreshold = 1
+	}
+	if p.FailureThreshold == 0 {
+		p.FailureThreshold = 3
+	}
+	return p
+}
+
+// describeProbe returns a human-readable description of what the specified probe checks
+func describeProbe(probe *corev1.Probe) string {
+	switch {
+	case probe.HTTPGet != nil:
+		return fmt.Sprintf("HTTP GET on port %s at %s", probe.HTTPGet.Port.String(), probe.HTTPGet.Path)
+	case probe.TCPSocket != nil:
+		return fmt.Sprintf("TCP connection on port %s", probe.TCPSocket.Port.String())
+	case probe.Exec != nil:
+		return fmt.Sprintf("exec %v", probe.Exec.Command)
+	default:
+		return "unknown check"
+	}
+}
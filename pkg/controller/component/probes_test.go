@@ -0,0 +1,86 @@
+package component
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"reflect"
+	"testing"
+)
+
+func TestCompletedProbe(t *testing.T) {
+	cases := []struct {
+		name     string
+		probe    *corev1.Probe
+		expected *corev1.Probe
+	}{
+		{name: "none"},
+		{
+			name:  "http defaults",
+			probe: &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{}}},
+			expected: &corev1.Probe{
+				Handler:          corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(8080), Scheme: corev1.URISchemeHTTP}},
+				TimeoutSeconds:   1,
+				PeriodSeconds:    10,
+				SuccessThreshold: 1,
+				FailureThreshold: 3,
+			},
+		},
+		{
+			name: "explicit values are kept",
+			probe: &corev1.Probe{
+				Handler:          corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/health", Port: intstr.FromString("management"), Scheme: corev1.URISchemeHTTPS}},
+				TimeoutSeconds:   5,
+				PeriodSeconds:    30,
+				SuccessThreshold: 2,
+				FailureThreshold: 10,
+			},
+			expected: &corev1.Probe{
+				Handler:          corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/health", Port: intstr.FromString("management"), Scheme: corev1.URISchemeHTTPS}},
+				TimeoutSeconds:   5,
+				PeriodSeconds:    30,
+				SuccessThreshold: 2,
+				FailureThreshold: 10,
+			},
+		},
+		{
+			name:  "tcp port",
+			probe: &corev1.Probe{Handler: corev1.Handler{TCPSocket: &corev1.TCPSocketAction{}}, InitialDelaySeconds: 20},
+			expected: &corev1.Probe{
+				Handler:             corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(8080)}},
+				InitialDelaySeconds: 20,
+				TimeoutSeconds:      1,
+				PeriodSeconds:       10,
+				SuccessThreshold:    1,
+				FailureThreshold:    3,
+			},
+		},
+	}
+	for _, c := range cases {
+		completed := completedProbe(c.probe, 8080)
+		if !reflect.DeepEqual(completed, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, completed)
+		}
+	}
+}
+
+func TestCompletedProbeDoesntModifySpecifiedProbe(t *testing.T) {
+	probe := &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{}}}
+	completedProbe(probe, 8080)
+	if probe.HTTPGet.Port.IntValue() != 0 || probe.PeriodSeconds != 0 {
+		t.Errorf("specified probe shouldn't be modified, got %v", probe)
+	}
+}
+
+func TestStartupProbeDelaysLiveness(t *testing.T) {
+	container := &corev1.Container{}
+	setProbes(container, probesConfig{
+		Liveness: &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{}}},
+		Startup:  &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{}}, PeriodSeconds: 5, FailureThreshold: 24},
+	}, 8080)
+	if container.LivenessProbe.InitialDelaySeconds != 120 {
+		t.Errorf("expected liveness to be delayed by 120s, got %ds", container.LivenessProbe.InitialDelaySeconds)
+	}
+	if container.ReadinessProbe != nil {
+		t.Errorf("expected no readiness probe, got %v", container.ReadinessProbe)
+	}
+}
@@ -0,0 +1,130 @@
+package component
+
+import (
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables providing the operator-wide registry configuration, used for components that don't specify their own
+const (
+	// RegistrySecretEnvVar holds the name of the docker config secret to use, expected to exist in each component namespace
+	RegistrySecretEnvVar = "REGISTRY_SECRET"
+	// RegistryCAConfigMapEnvVar holds the name of the config map providing the registry CA bundle, expected to exist in
+	// each component namespace
+	RegistryCAConfigMapEnvVar = "REGISTRY_CA_CONFIGMAP"
+	// RegistryInsecureEnvVar disables the verification of the registry certificates when set to "true"
+	RegistryInsecureEnvVar = "REGISTRY_INSECURE"
+)
+
+const (
+	registryCredentialsVolume = "registry-credentials"
+	registryCredentialsDir    = "/home/builder/.docker"
+	registryCAVolume          = "registry-ca"
+	registryCertsDir          = "/etc/containers/certs.d"
+)
+
+// registryConfig defines how builds access the registry the component's image is pushed to
+type registryConfig struct {
+	// Secret is the name of a kubernetes.io/dockerconfigjson secret holding the registry credentials
+	Secret string `json:"secret,omitempty"`
+	// CAConfigMap is the name of a config map holding the CA certificates of the registry in keys ending with ".crt"
+	CAConfigMap string `json:"caConfigMap,omitempty"`
+	// Insecure disables the verification of the registry certificates
+	Insecure *bool `json:"insecure,omitempty"`
+}
+
+// getRegistryConfig computes the registry configuration of the specified component, the configuration specified on the
+// component overriding the operator-wide one
+func getRegistryConfig(c *v1beta1.Component) (registryConfig, error) {
+	config := registryConfig{
+		Secret:      os.Getenv(RegistrySecretEnvVar),
+		CAConfigMap: os.Getenv(RegistryCAConfigMapEnvVar),
+	}
+	if insecure, ok := os.LookupEnv(RegistryInsecureEnvVar); ok && len(insecure) > 0 {
+		value, err := strconv.ParseBool(insecure)
+		if err != nil {
+			return config, fmt.Errorf("invalid '%s' value: %s", RegistryInsecureEnvVar, err.Error())
+		}
+		config.Insecure = &value
+	}
+
+	specified := registryConfig{}
+	if _, err := unmarshalAnnotation(c, RegistryAnnotation, &specified); err != nil {
+		return config, err
+	}
+	if len(specified.Secret) > 0 {
+		config.Secret = specified.Secret
+	}
+	if len(specified.CAConfigMap) > 0 {
+		config.CAConfigMap = specified.CAConfigMap
+	}
+	if specified.Insecure != nil {
+		config.Insecure = specified.Insecure
+	}
+	return config, nil
+}
+
+// verifyTLS returns the value of the verifyTLS Task parameter, certificates being verified unless explicitly disabled
+func (r registryConfig) verifyTLS() string {
+	return strconv.FormatBool(r.Insecure == nil || !*r.Insecure)
+}
+
+// configure mounts the registry credentials and CA certificates into all the steps of the specified Task
+func (r registryConfig) configure(spec *v1alpha1.TaskSpec, image string) {
+	mounts := make([]corev1.VolumeMount, 0, 2)
+	if len(r.Secret) > 0 {
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: registryCredentialsVolume,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName: r.Secret,
+				Items:      []corev1.KeyToPath{{Key: corev1.DockerConfigJsonKey, Path: "config.json"}},
+			}},
+		})
+		mounts = append(mounts, corev1.VolumeMount{Name: registryCredentialsVolume, MountPath: registryCredentialsDir, ReadOnly: true})
+	}
+	if len(r.CAConfigMap) > 0 {
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: registryCAVolume,
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: r.CAConfigMap},
+			}},
+		})
+		// buildah trusts the certificates found in the directory named after the registry host
+		mounts = append(mounts, corev1.VolumeMount{Name: registryCAVolume, MountPath: registryCertsDir + "/" + registryHost(image), ReadOnly: true})
+	}
+	if len(mounts) == 0 {
+		return
+	}
+	for i := range spec.Steps {
+		step := &spec.Steps[i]
+		step.VolumeMounts = append(step.VolumeMounts, mounts...)
+		if len(r.Secret) > 0 {
+			setEnvIfMissing(&step.Container, "REGISTRY_AUTH_FILE", registryCredentialsDir+"/config.json")
+			setEnvIfMissing(&step.Container, "DOCKER_CONFIG", registryCredentialsDir)
+		}
+	}
+}
+
+// registryHost returns the host (and port) of the registry the specified image reference points to
+func registryHost(image string) string {
+	if slash := strings.IndexRune(image, '/'); slash > 0 {
+		if host := image[:slash]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			return host
+		}
+	}
+	return "docker.io"
+}
+
+func setEnvIfMissing(container *corev1.Container, name, value string) {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return
+		}
+	}
+	container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: value})
+}
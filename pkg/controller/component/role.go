@@ -8,10 +8,11 @@ import (
 
 type role struct {
 	framework.Role
+	owner *Component
 }
 
 func newRole(owner *Component) role {
-	return role{Role: framework.NewOwnedRole(owner)}
+	return role{Role: framework.NewOwnedRole(owner), owner: owner}
 }
 
 func (res role) Build(empty bool) (runtime.Object, error) {
@@ -21,6 +22,16 @@ func (res role) Build(empty bool) (runtime.Object, error) {
 	}
 	r := ser.(*authorizv1.Role)
 	if !empty {
+		if !needsPrivilegedBuilds(res.owner.Component) {
+			// rootless builds, and dev mode components which don't build, don't need to use the privileged SCC
+			rules := make([]authorizv1.PolicyRule, 0, len(r.Rules))
+			for _, rule := range r.Rules {
+				if !usesSCC(rule) {
+					rules = append(rules, rule)
+				}
+			}
+			r.Rules = rules
+		}
 		r.Rules = append(r.Rules, authorizv1.PolicyRule{
 			APIGroups: []string{"image.openshift.io"},
 			Resources: []string{"imagestreams", "imagestreams/layers"},
@@ -30,3 +41,12 @@ func (res role) Build(empty bool) (runtime.Object, error) {
 
 	return r, nil
 }
+
+func usesSCC(rule authorizv1.PolicyRule) bool {
+	for _, group := range rule.APIGroups {
+		if group == "security.openshift.io" {
+			return true
+		}
+	}
+	return false
+}
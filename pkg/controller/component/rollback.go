@@ -0,0 +1,128 @@
+package component
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	halkyon "halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"time"
+)
+
+// Attributes recorded on the deployment condition to describe the images it ran
+const (
+	// DeployedImagesAttributeKey records the JSON-encoded history of the images deployed, newest first
+	DeployedImagesAttributeKey = "DeployedImages"
+	// RolledBackToAttributeKey records the image the deployment was rolled back to, if any
+	RolledBackToAttributeKey = "RolledBackTo"
+	// RollbackFailedAttributeKey records the requested rollback target when it isn't among the images previously deployed
+	RollbackFailedAttributeKey = "RollbackFailed"
+)
+
+// deployedImagesAnnotation records, on the component, the history of the images deployed by its build deployment so that
+// it survives the deployment being removed when switching to dev mode
+const deployedImagesAnnotation = "halkyon.io/deployed-images"
+
+// maxDeployedImages is the number of deployed images kept in the history, older ones being forgotten
+const maxDeployedImages = 10
+
+// deployedImage describes an image which was deployed along with the build which produced it
+type deployedImage struct {
+	Image      string `json:"image"`
+	Digest     string `json:"digest"`
+	Revision   string `json:"revision,omitempty"`
+	Build      string `json:"build,omitempty"`
+	BuiltAt    string `json:"builtAt,omitempty"`
+	DeployedAt string `json:"deployedAt"`
+}
+
+// deployedImages returns the history of the images deployed by the build deployment of the specified component, newest
+// first
+func deployedImages(c *halkyon.Component) []deployedImage {
+	var history []deployedImage
+	if value, ok := c.Annotations[deployedImagesAnnotation]; ok {
+		// a corrupted history is simply started over
+		_ = json.Unmarshal([]byte(value), &history)
+	}
+	return history
+}
+
+// recordDeployedImage adds the specified pinned image to the history of the given component, describing it with the
+// latest build if it produced the image or with its previous history entry when the deployment rolls back to it. The
+// component is only updated if the image isn't already the latest deployed one.
+func recordDeployedImage(c *halkyon.Component, image string) error {
+	history := deployedImages(c)
+	if !strings.Contains(image, "@") || (len(history) > 0 && history[0].Image == image) {
+		return nil
+	}
+	entry := deployedImage{Image: image, Digest: image[strings.Index(image, "@")+1:]}
+	kept := make([]deployedImage, 0, len(history)+1)
+	for _, previous := range history {
+		if previous.Digest == entry.Digest {
+			entry = previous
+			continue
+		}
+		kept = append(kept, previous)
+	}
+	if len(entry.Build) == 0 {
+		if run, err := fetchBuildRun(c); err == nil && run.isSucceeded() && builtImageDigest(run) == entry.Digest {
+			entry.Build = run.GetName()
+			entry.Revision = GitRevision(c)
+			entry.BuiltAt = run.succeeded.LastTransitionTime.Inner.UTC().Format(time.RFC3339)
+		}
+	}
+	entry.DeployedAt = time.Now().UTC().Format(time.RFC3339)
+	history = append([]deployedImage{entry}, kept...)
+	if len(history) > maxDeployedImages {
+		history = history[:maxDeployedImages]
+	}
+	value, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	if c.Annotations == nil {
+		c.Annotations = make(map[string]string, 1)
+	}
+	c.Annotations[deployedImagesAnnotation] = string(value)
+	if err := framework.Helper.Client.Update(context.TODO(), c); err != nil {
+		return fmt.Errorf("couldn't record %s as deployed: %s", image, err.Error())
+	}
+	return nil
+}
+
+// getRollback returns the digest, or image reference, of the previously deployed image the specified component should be
+// rolled back to, if any. The annotation holds the raw value, as set by 'kubectl annotate', quotes being ignored.
+func getRollback(c *halkyon.Component) string {
+	if halkyon.BuildDeploymentMode != c.Spec.DeploymentMode {
+		return ""
+	}
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(c.Annotations[RollbackAnnotation]), `"`))
+}
+
+// requestedRollback returns the image the build deployment of the specified component should be rolled back to, an empty
+// string if no rollback was requested or if the requested image isn't among the ones previously deployed, which is
+// reported by the deployment condition rather than failing the reconciliation
+func requestedRollback(c *halkyon.Component) string {
+	rollback := getRollback(c)
+	if len(rollback) == 0 {
+		return ""
+	}
+	for _, entry := range deployedImages(c) {
+		if entry.Digest == rollback || entry.Image == rollback {
+			return entry.Image
+		}
+	}
+	return ""
+}
+
+// recordBuildDeploymentImage records the image the build deployment of the specified component runs in its history. It
+// is meant to be called once the deployment was updated so that only images which were actually deployed are recorded.
+func recordBuildDeploymentImage(c *halkyon.Component) error {
+	d, err := fetchDeployment(c.DeploymentName(), c.Namespace)
+	if err != nil || d == nil || !metav1.IsControlledBy(d, c) || len(d.Spec.Template.Spec.Containers) == 0 {
+		return err
+	}
+	return recordDeployedImage(c, d.Spec.Template.Spec.Containers[0].Image)
+}
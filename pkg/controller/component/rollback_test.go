@@ -0,0 +1,37 @@
+package component
+
+import (
+	halkyon "halkyon.io/api/component/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestRequestedRollback(t *testing.T) {
+	history := `[{"image":"registry.local/demo/fruits@sha256:2222","digest":"sha256:2222","deployedAt":"2020-05-02T10:00:00Z"},` +
+		`{"image":"registry.local/demo/fruits@sha256:1111","digest":"sha256:1111","deployedAt":"2020-05-01T10:00:00Z"}]`
+	cases := []struct {
+		name     string
+		mode     halkyon.DeploymentMode
+		rollback string
+		expected string
+	}{
+		{name: "no rollback", mode: halkyon.BuildDeploymentMode},
+		{name: "digest", mode: halkyon.BuildDeploymentMode, rollback: "sha256:1111", expected: "registry.local/demo/fruits@sha256:1111"},
+		{name: "quoted digest", mode: halkyon.BuildDeploymentMode, rollback: `"sha256:1111"`, expected: "registry.local/demo/fruits@sha256:1111"},
+		{name: "image", mode: halkyon.BuildDeploymentMode, rollback: " registry.local/demo/fruits@sha256:1111 ", expected: "registry.local/demo/fruits@sha256:1111"},
+		{name: "unknown image", mode: halkyon.BuildDeploymentMode, rollback: "sha256:0000"},
+		{name: "dev mode", mode: halkyon.DevDeploymentMode, rollback: "sha256:1111"},
+	}
+	for _, c := range cases {
+		component := &halkyon.Component{
+			ObjectMeta: metav1.ObjectMeta{Name: "fruits", Namespace: "demo", Annotations: map[string]string{deployedImagesAnnotation: history}},
+			Spec:       halkyon.ComponentSpec{DeploymentMode: c.mode},
+		}
+		if len(c.rollback) > 0 {
+			component.Annotations[RollbackAnnotation] = c.rollback
+		}
+		if rollback := requestedRollback(component); rollback != c.expected {
+			t.Errorf("expected '%s' rollback to %s, got '%s'", c.name, c.expected, rollback)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	routev1 "github.com/openshift/api/route/v1"
+	halkyon "halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RolloutAttributeKey records, on the deployment condition, the phase the rollout of a new image is in
+const RolloutAttributeKey = "Rollout"
+
+// Phases of a progressive rollout: the new image is first deployed in a candidate deployment which doesn't get any traffic,
+// the traffic is then shifted to the candidate step by step, the main deployment being updated to run the new image once
+// all steps were advanced and the candidate being removed once the traffic was routed back to the main deployment
+const (
+	rolloutDeployingCandidate = "DeployingCandidate"
+	rolloutShiftingTraffic    = "ShiftingTraffic"
+	rolloutPromoting          = "Promoting"
+	rolloutRemovingCandidate  = "RemovingCandidate"
+)
+
+const (
+	rollingRollout   = "rolling"
+	blueGreenRollout = "blueGreen"
+	canaryRollout    = "canary"
+	autoPromotion    = "auto"
+	manualPromotion  = "manual"
+	// defaultRolloutInterval is how long, in seconds, the candidate must have been ready before a step is automatically
+	// advanced
+	defaultRolloutInterval = int32(60)
+	// annotations recording the progress of a rollout on the candidate deployment
+	rolloutStepAnnotation        = "halkyon.io/rollout-step"
+	rolloutStepTimeAnnotation    = "halkyon.io/rollout-step-time"
+	rolloutAdvanceBaseAnnotation = "halkyon.io/rollout-advance-base"
+	// annotations making the NGINX ingress controller route a share of the traffic of a host to another ingress' backend
+	nginxCanaryAnnotation       = "nginx.ingress.kubernetes.io/canary"
+	nginxCanaryWeightAnnotation = "nginx.ingress.kubernetes.io/canary-weight"
+)
+
+var defaultCanaryWeights = []int32{10, 50}
+
+// rolloutConfig defines how new images of a build mode component are rolled out
+type rolloutConfig struct {
+	// Strategy is either rolling, the default, updating the deployment in place, blueGreen, switching all the traffic to
+	// the new image at once, or canary, routing increasing shares of the traffic to the new image
+	Strategy string `json:"strategy,omitempty"`
+	// Weights are the percentages of the traffic routed to the new image at each step of a canary rollout
+	Weights []int32 `json:"weights,omitempty"`
+	// Promotion is either auto, steps being advanced once the new image's pods have been ready for Interval seconds, or
+	// manual, steps being advanced by incrementing the RolloutAdvanceAnnotation
+	Promotion string `json:"promotion,omitempty"`
+	Interval  *int32 `json:"interval,omitempty"`
+	// advance is the current value of the RolloutAdvanceAnnotation
+	advance int
+}
+
+// getRolloutConfig returns the rollout configuration of the specified component, which only applies in build mode
+func getRolloutConfig(c *halkyon.Component) (rolloutConfig, error) {
+	config := rolloutConfig{Strategy: rollingRollout, Promotion: autoPromotion}
+	if halkyon.BuildDeploymentMode != c.Spec.DeploymentMode {
+		return config, nil
+	}
+	if _, err := unmarshalAnnotation(c, RolloutAnnotation, &config); err != nil {
+		return config, err
+	}
+	switch config.Strategy {
+	case rollingRollout:
+	case blueGreenRollout:
+		if len(config.Weights) > 0 {
+			return config, fmt.Errorf("weights only apply to %s rollouts in '%s' annotation", canaryRollout, RolloutAnnotation)
+		}
+	case canaryRollout:
+		if !c.Spec.ExposeService {
+			return config, fmt.Errorf("%s rollouts require the component to expose its service", canaryRollout)
+		}
+		if len(config.Weights) == 0 {
+			config.Weights = defaultCanaryWeights
+		}
+		previous := int32(0)
+		for _, weight := range config.Weights {
+			if weight <= previous || weight >= 100 {
+				return config, fmt.Errorf("weights must be increasing percentages between 1 and 99 in '%s' annotation", RolloutAnnotation)
+			}
+			previous = weight
+		}
+	default:
+		return config, fmt.Errorf("unknown rollout strategy '%s' in '%s' annotation, must be one of %s, %s or %s", config.Strategy, RolloutAnnotation, rollingRollout, blueGreenRollout, canaryRollout)
+	}
+	switch config.Promotion {
+	case autoPromotion, manualPromotion:
+	default:
+		return config, fmt.Errorf("unknown promotion '%s' in '%s' annotation, must be either %s or %s", config.Promotion, RolloutAnnotation, autoPromotion, manualPromotion)
+	}
+	if config.Interval == nil {
+		interval := defaultRolloutInterval
+		config.Interval = &interval
+	} else if *config.Interval < 0 {
+		return config, fmt.Errorf("interval cannot be negative in '%s' annotation", RolloutAnnotation)
+	}
+	if _, err := unmarshalAnnotation(c, RolloutAdvanceAnnotation, &config.advance); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// isProgressive checks whether new images are first deployed alongside the current one rather than in place
+func (in rolloutConfig) isProgressive() bool {
+	return in.Strategy != rollingRollout
+}
+
+// steps returns the number of steps shifting traffic to the new image, which is promoted when advancing past the last one
+func (in rolloutConfig) steps() int {
+	if in.Strategy == canaryRollout {
+		return len(in.Weights)
+	}
+	return 1
+}
+
+// weightAt returns the percentage of the traffic routed to the candidate of a canary rollout at the specified step, the
+// last weight applying past the last step
+func (in rolloutConfig) weightAt(step int) int32 {
+	if in.Strategy != canaryRollout || step < 1 {
+		return 0
+	}
+	if step > len(in.Weights) {
+		step = len(in.Weights)
+	}
+	return in.Weights[step-1]
+}
+
+// rollout is the state of the progressive rollout of a new image of a component, the image running in a candidate
+// deployment until it is promoted to the main deployment
+type rollout struct {
+	config    rolloutConfig
+	main      *appsv1.Deployment
+	candidate *appsv1.Deployment
+	step      int
+}
+
+// currentRollout returns the state of the rollout of the specified component, the candidate being nil when no rollout is
+// in progress
+func currentRollout(c *halkyon.Component) (*rollout, error) {
+	config, err := getRolloutConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	r := &rollout{config: config}
+	if r.main, err = fetchDeployment(c.DeploymentName(), c.Namespace); err != nil {
+		return nil, err
+	}
+	if r.candidate, err = fetchDeployment(CandidateName(c), c.Namespace); err != nil || r.candidate == nil {
+		return r, err
+	}
+	r.step, _ = strconv.Atoi(r.candidate.Annotations[rolloutStepAnnotation])
+	return r, nil
+}
+
+// deploymentImage returns the image run by the specified deployment
+func deploymentImage(d *appsv1.Deployment) string {
+	if d == nil || len(d.Spec.Template.Spec.Containers) == 0 {
+		return ""
+	}
+	return d.Spec.Template.Spec.Containers[0].Image
+}
+
+// hasRolledOut checks whether the specified deployment rolled out its current spec with all its pods available
+func hasRolledOut(d *appsv1.Deployment) bool {
+	return isDeploymentAvailable(d) && d.Status.UpdatedReplicas == d.Status.Replicas && d.Status.AvailableReplicas == d.Status.Replicas
+}
+
+// promoted checks whether the main deployment was updated to run the image of the candidate
+func (r *rollout) promoted() bool {
+	return r.candidate != nil && deploymentImage(r.main) == deploymentImage(r.candidate)
+}
+
+// candidateServes checks whether traffic is routed to the candidate: from the first step until the main deployment rolled
+// out the promoted image
+func (r *rollout) candidateServes() bool {
+	if r.candidate == nil || r.step < 1 {
+		return false
+	}
+	return !r.promoted() || !hasRolledOut(r.main)
+}
+
+// canaryWeight returns the percentage of the traffic routed to the candidate of a canary rollout
+func (r *rollout) canaryWeight() int32 {
+	if !r.candidateServes() {
+		return 0
+	}
+	return r.config.weightAt(r.step)
+}
+
+// candidateReplicas returns the replicas the candidate should run at the specified step: a blue/green candidate gets all
+// the traffic at once so it runs as many replicas as the main deployment while a canary candidate runs its share of them,
+// sized for the share of the next step so that it is already scaled when the traffic gets shifted to it
+func (r *rollout) candidateReplicas(step int) int32 {
+	replicas := int32(1)
+	if r.main == nil || r.main.Status.Replicas <= replicas {
+		return replicas
+	}
+	switch r.config.Strategy {
+	case blueGreenRollout:
+		return r.main.Status.Replicas
+	case canaryRollout:
+		// round up so that the candidate never runs less than its share
+		if share := (r.main.Status.Replicas*r.config.weightAt(step+1) + 99) / 100; share > replicas {
+			return share
+		}
+	}
+	return replicas
+}
+
+// serviceTarget returns the name of the deployment the service of the specified component should route the traffic to,
+// which is the candidate once a blue/green rollout switched to it
+func (r *rollout) serviceTarget(c *halkyon.Component) string {
+	if r.config.Strategy == blueGreenRollout && r.candidateServes() {
+		return CandidateName(c)
+	}
+	return c.DeploymentName()
+}
+
+// holds checks whether the main deployment should keep running its current image instead of the specified one, which is
+// the case when the new image is rolled out progressively until it's promoted. Images which aren't pinned by digest are
+// always deployed in place.
+func (r *rollout) holds(current, image string) bool {
+	if !r.config.isProgressive() || current == image || !strings.Contains(current, "@") || !strings.Contains(image, "@") {
+		return false
+	}
+	return r.candidate == nil || deploymentImage(r.candidate) != image || r.step <= r.config.steps()
+}
+
+// phase returns the phase the rollout is in along with a description of its progress, an empty phase meaning that no
+// rollout is in progress
+func (r *rollout) phase(c *halkyon.Component) (string, string) {
+	if r.candidate == nil {
+		return "", ""
+	}
+	name, image := r.candidate.Name, deploymentImage(r.candidate)
+	switch {
+	case r.promoted() && !r.candidateServes():
+		return rolloutRemovingCandidate, fmt.Sprintf("promoted %s: removing '%s' deployment", image, name)
+	case r.step > r.config.steps() || r.promoted():
+		return rolloutPromoting, fmt.Sprintf("promoting %s: waiting for '%s' deployment to roll it out", image, c.DeploymentName())
+	}
+	var progress string
+	switch {
+	case r.step == 0:
+		progress = fmt.Sprintf("rolling out %s in '%s' deployment, not routing traffic to it yet", image, name)
+	case r.config.Strategy == blueGreenRollout:
+		progress = fmt.Sprintf("rolling out %s: all traffic routed to '%s' deployment", image, name)
+	default:
+		progress = fmt.Sprintf("rolling out %s: step %d/%d, %d%% of the traffic routed to '%s' deployment", image, r.step, r.config.steps(), r.canaryWeight(), name)
+	}
+	if r.config.Promotion == manualPromotion {
+		progress += fmt.Sprintf(", increment '%s' annotation to advance", RolloutAdvanceAnnotation)
+	}
+	if r.step == 0 {
+		return rolloutDeployingCandidate, progress
+	}
+	return rolloutShiftingTraffic, progress
+}
+
+// approved checks whether the current step can be advanced: manually, when the RolloutAdvanceAnnotation was incremented
+// past it since the rollout started, or automatically, once the candidate has been ready for the configured interval
+func (r *rollout) approved() bool {
+	if r.config.Promotion == manualPromotion {
+		base, _ := strconv.Atoi(r.candidate.Annotations[rolloutAdvanceBaseAnnotation])
+		return r.config.advance-base > r.step
+	}
+	since, _ := time.Parse(time.RFC3339, r.candidate.Annotations[rolloutStepTimeAnnotation])
+	for _, condition := range r.candidate.Status.Conditions {
+		if condition.Type == appsv1.DeploymentAvailable && condition.Status == corev1.ConditionTrue && condition.LastTransitionTime.After(since) {
+			since = condition.LastTransitionTime.Time
+		}
+	}
+	return time.Since(since) >= time.Duration(*r.config.Interval)*time.Second
+}
+
+// progressRollout advances the rollout of the new image of the specified component, if any, returning whether a rollout
+// is in progress
+func progressRollout(c *halkyon.Component) (bool, error) {
+	r, err := currentRollout(c)
+	if err != nil || r.main == nil {
+		return false, err
+	}
+	if r.candidate != nil && c.Spec.ExposeService && !framework.IsTargetClusterRunningOpenShift() {
+		if err := r.syncCanaryIngress(c); err != nil {
+			return true, err
+		}
+	}
+	if !r.config.isProgressive() || len(requestedRollback(c)) > 0 {
+		if r.candidate == nil {
+			return false, nil
+		}
+		return r.retire(c)
+	}
+	current := deploymentImage(r.main)
+	image, _ := builtImage(c, current)
+	switch {
+	case r.candidate == nil:
+		if !r.holds(current, image) {
+			return false, nil
+		}
+		return true, r.deployCandidate(c, image)
+	case r.promoted():
+		return r.retire(c)
+	case deploymentImage(r.candidate) == image:
+		return true, r.advance()
+	case r.holds(current, image):
+		// a newer image was built during the rollout, which restarts with it
+		return true, r.deployCandidate(c, image)
+	default:
+		return r.retire(c)
+	}
+}
+
+// deployCandidate deploys the specified image in the candidate deployment, derived from the main one, the rollout
+// starting over from its first step
+func (r *rollout) deployCandidate(c *halkyon.Component, image string) error {
+	name := CandidateName(c)
+	ls := appLabelsFor(name)
+	spec := r.main.Spec.DeepCopy()
+	spec.Selector = &metav1.LabelSelector{MatchLabels: ls}
+	spec.Template.Labels = ls
+	container := &spec.Template.Spec.Containers[0]
+	container.Image = image
+	container.ImagePullPolicy = corev1.PullIfNotPresent
+	replicas := r.candidateReplicas(0)
+	spec.Replicas = &replicas
+	annotations := map[string]string{
+		rolloutStepAnnotation:        "0",
+		rolloutStepTimeAnnotation:    time.Now().Format(time.RFC3339),
+		rolloutAdvanceBaseAnnotation: strconv.Itoa(r.config.advance),
+	}
+
+	if r.config.Strategy == canaryRollout {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.Namespace, Labels: ls},
+			Spec:       serviceSpecFor(c, ls),
+		}
+		if err := createOwned(c, svc); err != nil {
+			return err
+		}
+	}
+	if r.candidate != nil {
+		r.candidate.Spec = *spec
+		r.candidate.Annotations = annotations
+		return framework.Helper.Client.Update(context.TODO(), r.candidate)
+	}
+	candidate := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.Namespace, Labels: ls, Annotations: annotations},
+		Spec:       *spec,
+	}
+	return createOwned(c, candidate)
+}
+
+// createOwned creates the specified object, owned by the given component, unless it already exists
+func createOwned(c *halkyon.Component, object metav1.Object) error {
+	if err := controllerutil.SetControllerReference(c, object, framework.Helper.Scheme); err != nil {
+		return err
+	}
+	if err := framework.Helper.Client.Create(context.TODO(), object.(runtime.Object)); err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("couldn't create '%s' for rollout: %s", object.GetName(), err.Error())
+	}
+	return nil
+}
+
+// advance moves the rollout to its next step once the candidate rolled out and the step was approved
+func (r *rollout) advance() error {
+	if r.step > r.config.steps() || !hasRolledOut(r.candidate) || !r.approved() {
+		return nil
+	}
+	return r.setStep(r.step + 1)
+}
+
+// setStep records the step the rollout reached on the candidate deployment, scaling it for this step
+func (r *rollout) setStep(step int) error {
+	if r.candidate.Annotations == nil {
+		r.candidate.Annotations = make(map[string]string, 2)
+	}
+	replicas := r.candidateReplicas(step)
+	r.candidate.Spec.Replicas = &replicas
+	r.candidate.Annotations[rolloutStepAnnotation] = strconv.Itoa(step)
+	r.candidate.Annotations[rolloutStepTimeAnnotation] = time.Now().Format(time.RFC3339)
+	return framework.Helper.Client.Update(context.TODO(), r.candidate)
+}
+
+// retire removes the candidate once the rollout was promoted or abandoned, after the traffic was routed back to the main
+// deployment
+func (r *rollout) retire(c *halkyon.Component) (bool, error) {
+	if r.candidateServes() {
+		if r.promoted() {
+			return true, nil
+		}
+		return true, r.setStep(0)
+	}
+	if routed, err := routesToCandidate(c); err != nil || routed {
+		return true, err
+	}
+	meta := metav1.ObjectMeta{Name: CandidateName(c), Namespace: c.Namespace}
+	for _, object := range []runtime.Object{&corev1.Service{ObjectMeta: meta}, &appsv1.Deployment{ObjectMeta: meta}} {
+		if err := framework.Helper.Client.Delete(context.TODO(), object, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return true, fmt.Errorf("couldn't remove '%s' candidate after rollout: %s", meta.Name, err.Error())
+		}
+	}
+	return false, nil
+}
+
+// routesToCandidate checks whether the service or route of the specified component still route traffic to the candidate
+func routesToCandidate(c *halkyon.Component) (bool, error) {
+	name := framework.DefaultDependentResourceNameFor(c)
+	svc := &corev1.Service{}
+	if _, err := framework.Helper.Fetch(name, c.Namespace, svc); err != nil && !errors.IsNotFound(err) {
+		return false, err
+	} else if err == nil && svc.Spec.Selector["app"] == CandidateName(c) {
+		return true, nil
+	}
+	if !framework.IsTargetClusterRunningOpenShift() || !c.Spec.ExposeService {
+		return false, nil
+	}
+	route := &routev1.Route{}
+	if _, err := framework.Helper.Fetch(name, c.Namespace, route); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return len(route.Spec.AlternateBackends) > 0, nil
+}
+
+// syncCanaryIngress makes the NGINX ingress controller route the current canary weight of the traffic to the candidate,
+// using an ingress for the same host annotated as canary, which is removed when no traffic should go to the candidate
+func (r *rollout) syncCanaryIngress(c *halkyon.Component) error {
+	name := CandidateName(c)
+	weight := r.canaryWeight()
+	canary := &v1beta1.Ingress{}
+	if _, err := framework.Helper.Fetch(name, c.Namespace, canary); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if weight == 0 {
+			return nil
+		}
+		canary = &v1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: c.Namespace,
+				Labels:    appLabelsFor(name),
+				Annotations: map[string]string{
+					nginxCanaryAnnotation:       "true",
+					nginxCanaryWeightAnnotation: strconv.Itoa(int(weight)),
+				},
+			},
+			Spec: ingressSpecFor(c, name),
+		}
+		return createOwned(c, canary)
+	}
+	if weight == 0 {
+		if err := framework.Helper.Client.Delete(context.TODO(), canary); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	if canary.Annotations[nginxCanaryWeightAnnotation] == strconv.Itoa(int(weight)) {
+		return nil
+	}
+	if canary.Annotations == nil {
+		canary.Annotations = make(map[string]string, 2)
+	}
+	canary.Annotations[nginxCanaryAnnotation] = "true"
+	canary.Annotations[nginxCanaryWeightAnnotation] = strconv.Itoa(int(weight))
+	return framework.Helper.Client.Update(context.TODO(), canary)
+}
@@ -0,0 +1,271 @@
+package component
+
+import (
+	halkyon "halkyon.io/api/component/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	currentImage = "registry.local/demo/fruits@sha256:1111"
+	newImage     = "registry.local/demo/fruits@sha256:2222"
+)
+
+func rolledOutComponent(mode halkyon.DeploymentMode, exposed bool, rollout string) *halkyon.Component {
+	c := &halkyon.Component{
+		ObjectMeta: metav1.ObjectMeta{Name: "fruits", Namespace: "demo"},
+		Spec:       halkyon.ComponentSpec{DeploymentMode: mode, ExposeService: exposed},
+	}
+	if len(rollout) > 0 {
+		c.Annotations = map[string]string{RolloutAnnotation: rollout}
+	}
+	return c
+}
+
+// deploymentRunning returns a deployment running the specified image, which rolled out its replicas unless specified
+// otherwise
+func deploymentRunning(image string, replicas int32, rolledOut bool) *appsv1.Deployment {
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Generation: 1, Annotations: map[string]string{}},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Image: image}}}},
+		},
+		Status: appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: replicas, UpdatedReplicas: replicas, AvailableReplicas: replicas},
+	}
+	if !rolledOut {
+		d.Status.UpdatedReplicas = 0
+	}
+	return d
+}
+
+func canaryRolloutAt(step int, candidate *appsv1.Deployment, main *appsv1.Deployment) *rollout {
+	interval := defaultRolloutInterval
+	return &rollout{
+		config:    rolloutConfig{Strategy: canaryRollout, Weights: []int32{10, 50}, Promotion: autoPromotion, Interval: &interval},
+		main:      main,
+		candidate: candidate,
+		step:      step,
+	}
+}
+
+func TestGetRolloutConfig(t *testing.T) {
+	cases := []struct {
+		name     string
+		mode     halkyon.DeploymentMode
+		exposed  bool
+		rollout  string
+		advance  string
+		expected rolloutConfig
+		valid    bool
+	}{
+		{name: "default", mode: halkyon.BuildDeploymentMode, expected: rolloutConfig{Strategy: rollingRollout, Promotion: autoPromotion, Interval: int32Ptr(defaultRolloutInterval)}, valid: true},
+		{name: "dev mode", mode: halkyon.DevDeploymentMode, rollout: `{"strategy":"unknown"}`, expected: rolloutConfig{Strategy: rollingRollout, Promotion: autoPromotion}, valid: true},
+		{name: "canary defaults", mode: halkyon.BuildDeploymentMode, exposed: true, rollout: `{"strategy":"canary"}`, expected: rolloutConfig{Strategy: canaryRollout, Weights: defaultCanaryWeights, Promotion: autoPromotion, Interval: int32Ptr(defaultRolloutInterval)}, valid: true},
+		{name: "manual blue/green", mode: halkyon.BuildDeploymentMode, rollout: `{"strategy":"blueGreen","promotion":"manual","interval":0}`, advance: "2", expected: rolloutConfig{Strategy: blueGreenRollout, Promotion: manualPromotion, Interval: int32Ptr(0), advance: 2}, valid: true},
+		{name: "canary without service", mode: halkyon.BuildDeploymentMode, rollout: `{"strategy":"canary"}`, valid: false},
+		{name: "decreasing weights", mode: halkyon.BuildDeploymentMode, exposed: true, rollout: `{"strategy":"canary","weights":[50,10]}`, valid: false},
+		{name: "full weight", mode: halkyon.BuildDeploymentMode, exposed: true, rollout: `{"strategy":"canary","weights":[10,100]}`, valid: false},
+		{name: "blue/green weights", mode: halkyon.BuildDeploymentMode, rollout: `{"strategy":"blueGreen","weights":[10]}`, valid: false},
+		{name: "unknown strategy", mode: halkyon.BuildDeploymentMode, rollout: `{"strategy":"shadow"}`, valid: false},
+		{name: "unknown promotion", mode: halkyon.BuildDeploymentMode, rollout: `{"strategy":"blueGreen","promotion":"later"}`, valid: false},
+		{name: "negative interval", mode: halkyon.BuildDeploymentMode, rollout: `{"strategy":"blueGreen","interval":-1}`, valid: false},
+		{name: "invalid advance", mode: halkyon.BuildDeploymentMode, rollout: `{"strategy":"blueGreen"}`, advance: "next", valid: false},
+	}
+	for _, c := range cases {
+		component := rolledOutComponent(c.mode, c.exposed, c.rollout)
+		if len(c.advance) > 0 {
+			component.Annotations[RolloutAdvanceAnnotation] = c.advance
+		}
+		config, err := getRolloutConfig(component)
+		if c.valid != (err == nil) {
+			t.Errorf("expected '%s' rollout validity to be %t, got error: %v", c.name, c.valid, err)
+			continue
+		}
+		if !c.valid {
+			continue
+		}
+		// DeepEqual compares the intervals the configurations point to
+		if !reflect.DeepEqual(config, c.expected) {
+			t.Errorf("expected %+v '%s' rollout, got %+v", c.expected, c.name, config)
+		}
+	}
+}
+
+func TestRolloutHolds(t *testing.T) {
+	canary := canaryRolloutAt(0, nil, deploymentRunning(currentImage, 2, true))
+	cases := []struct {
+		name      string
+		strategy  string
+		candidate *appsv1.Deployment
+		step      int
+		current   string
+		image     string
+		holds     bool
+	}{
+		{name: "rolling", strategy: rollingRollout, current: currentImage, image: newImage, holds: false},
+		{name: "same image", strategy: canaryRollout, current: currentImage, image: currentImage, holds: false},
+		{name: "unpinned current image", strategy: canaryRollout, current: "registry.local/demo/fruits", image: newImage, holds: false},
+		{name: "unpinned new image", strategy: canaryRollout, current: currentImage, image: "registry.local/demo/fruits", holds: false},
+		{name: "no candidate", strategy: canaryRollout, current: currentImage, image: newImage, holds: true},
+		{name: "other candidate", strategy: canaryRollout, candidate: deploymentRunning("registry.local/demo/fruits@sha256:0000", 1, true), step: 3, current: currentImage, image: newImage, holds: true},
+		{name: "shifting traffic", strategy: canaryRollout, candidate: deploymentRunning(newImage, 1, true), step: 2, current: currentImage, image: newImage, holds: true},
+		{name: "promoting", strategy: canaryRollout, candidate: deploymentRunning(newImage, 1, true), step: 3, current: currentImage, image: newImage, holds: false},
+		{name: "blue/green promoting", strategy: blueGreenRollout, candidate: deploymentRunning(newImage, 1, true), step: 2, current: currentImage, image: newImage, holds: false},
+	}
+	for _, c := range cases {
+		r := *canary
+		r.config.Strategy = c.strategy
+		r.candidate = c.candidate
+		r.step = c.step
+		if holds := r.holds(c.current, c.image); holds != c.holds {
+			t.Errorf("expected '%s' rollout holding the current image to be %t, got %t", c.name, c.holds, holds)
+		}
+	}
+}
+
+func TestCandidateServesAndCanaryWeight(t *testing.T) {
+	cases := []struct {
+		name   string
+		r      *rollout
+		serves bool
+		weight int32
+	}{
+		{name: "no candidate", r: canaryRolloutAt(0, nil, deploymentRunning(currentImage, 2, true))},
+		{name: "deploying candidate", r: canaryRolloutAt(0, deploymentRunning(newImage, 1, false), deploymentRunning(currentImage, 2, true))},
+		{name: "first step", r: canaryRolloutAt(1, deploymentRunning(newImage, 1, true), deploymentRunning(currentImage, 2, true)), serves: true, weight: 10},
+		{name: "last step", r: canaryRolloutAt(2, deploymentRunning(newImage, 1, true), deploymentRunning(currentImage, 2, true)), serves: true, weight: 50},
+		{name: "past last step", r: canaryRolloutAt(3, deploymentRunning(newImage, 1, true), deploymentRunning(currentImage, 2, true)), serves: true, weight: 50},
+		{name: "promoted image rolling out", r: canaryRolloutAt(3, deploymentRunning(newImage, 1, true), deploymentRunning(newImage, 2, false)), serves: true, weight: 50},
+		{name: "promoted image rolled out", r: canaryRolloutAt(3, deploymentRunning(newImage, 1, true), deploymentRunning(newImage, 2, true))},
+	}
+	for _, c := range cases {
+		if serves := c.r.candidateServes(); serves != c.serves {
+			t.Errorf("expected candidate serving traffic to be %t when '%s', got %t", c.serves, c.name, serves)
+		}
+		if weight := c.r.canaryWeight(); weight != c.weight {
+			t.Errorf("expected %d%% canary weight when '%s', got %d%%", c.weight, c.name, weight)
+		}
+		blueGreen := *c.r
+		blueGreen.config.Strategy = blueGreenRollout
+		if weight := blueGreen.canaryWeight(); weight != 0 {
+			t.Errorf("expected no canary weight for blue/green rollout when '%s', got %d%%", c.name, weight)
+		}
+	}
+}
+
+func TestCandidateReplicas(t *testing.T) {
+	cases := []struct {
+		name     string
+		strategy string
+		main     int32
+		step     int
+		replicas int32
+	}{
+		{name: "canary of a single replica", strategy: canaryRollout, main: 1, step: 1, replicas: 1},
+		{name: "canary before first step", strategy: canaryRollout, main: 4, step: 0, replicas: 1},
+		{name: "canary at first step", strategy: canaryRollout, main: 4, step: 1, replicas: 2},
+		{name: "canary at last step", strategy: canaryRollout, main: 4, step: 2, replicas: 2},
+		{name: "canary of many replicas", strategy: canaryRollout, main: 20, step: 0, replicas: 2},
+		{name: "blue/green", strategy: blueGreenRollout, main: 3, step: 0, replicas: 3},
+	}
+	for _, c := range cases {
+		r := canaryRolloutAt(c.step, nil, deploymentRunning(currentImage, c.main, true))
+		r.config.Strategy = c.strategy
+		if replicas := r.candidateReplicas(c.step); replicas != c.replicas {
+			t.Errorf("expected %d candidate replica(s) for '%s', got %d", c.replicas, c.name, replicas)
+		}
+	}
+}
+
+func TestRolloutPhase(t *testing.T) {
+	c := rolledOutComponent(halkyon.BuildDeploymentMode, true, `{"strategy":"canary"}`)
+	manual := canaryRolloutAt(1, deploymentRunning(newImage, 1, true), deploymentRunning(currentImage, 2, true))
+	manual.config.Promotion = manualPromotion
+	cases := []struct {
+		name     string
+		r        *rollout
+		phase    string
+		progress string
+	}{
+		{name: "no rollout", r: canaryRolloutAt(0, nil, deploymentRunning(currentImage, 2, true))},
+		{name: "deploying", r: canaryRolloutAt(0, deploymentRunning(newImage, 1, false), deploymentRunning(currentImage, 2, true)), phase: rolloutDeployingCandidate, progress: "not routing traffic to it yet"},
+		{name: "shifting", r: canaryRolloutAt(2, deploymentRunning(newImage, 1, true), deploymentRunning(currentImage, 2, true)), phase: rolloutShiftingTraffic, progress: "step 2/2, 50% of the traffic"},
+		{name: "manual", r: manual, phase: rolloutShiftingTraffic, progress: "increment '" + RolloutAdvanceAnnotation + "' annotation to advance"},
+		{name: "promoting", r: canaryRolloutAt(3, deploymentRunning(newImage, 1, true), deploymentRunning(currentImage, 2, true)), phase: rolloutPromoting, progress: "promoting " + newImage},
+		{name: "promoted", r: canaryRolloutAt(3, deploymentRunning(newImage, 1, true), deploymentRunning(newImage, 2, false)), phase: rolloutPromoting, progress: "waiting for"},
+		{name: "removing", r: canaryRolloutAt(3, deploymentRunning(newImage, 1, true), deploymentRunning(newImage, 2, true)), phase: rolloutRemovingCandidate, progress: "promoted " + newImage},
+	}
+	for _, tc := range cases {
+		phase, progress := tc.r.phase(c)
+		if phase != tc.phase {
+			t.Errorf("expected '%s' phase when '%s', got '%s'", tc.phase, tc.name, phase)
+		}
+		if !strings.Contains(progress, tc.progress) {
+			t.Errorf("expected progress of '%s' to mention '%s', got '%s'", tc.name, tc.progress, progress)
+		}
+	}
+}
+
+// TestAdvanceWaits only covers the cases where the step isn't advanced, advancing it requiring to update the candidate
+func TestAdvanceWaits(t *testing.T) {
+	now := time.Now().Format(time.RFC3339)
+	cases := []struct {
+		name string
+		r    *rollout
+	}{
+		{name: "candidate rolling out", r: canaryRolloutAt(1, deploymentRunning(newImage, 1, false), deploymentRunning(currentImage, 2, true))},
+		{name: "past last step", r: canaryRolloutAt(3, deploymentRunning(newImage, 1, true), deploymentRunning(currentImage, 2, true))},
+		{name: "interval not elapsed", r: canaryRolloutAt(1, deploymentRunning(newImage, 1, true), deploymentRunning(currentImage, 2, true))},
+		{name: "not manually advanced", r: canaryRolloutAt(1, deploymentRunning(newImage, 1, true), deploymentRunning(currentImage, 2, true))},
+	}
+	cases[2].r.candidate.Annotations[rolloutStepTimeAnnotation] = now
+	manual := cases[3].r
+	manual.config.Promotion = manualPromotion
+	manual.config.advance = 3
+	manual.candidate.Annotations[rolloutAdvanceBaseAnnotation] = "2"
+	for _, c := range cases {
+		step := c.r.step
+		if err := c.r.advance(); err != nil {
+			t.Errorf("expected '%s' rollout to wait, got error: %v", c.name, err)
+		}
+		if c.r.step != step || c.r.candidate.Annotations[rolloutStepAnnotation] != "" {
+			t.Errorf("expected '%s' rollout to stay at step %d, got %d", c.name, step, c.r.step)
+		}
+	}
+
+	manual.config.advance = 4
+	if !manual.approved() {
+		t.Errorf("expected incrementing '%s' past the current step to approve it", RolloutAdvanceAnnotation)
+	}
+	auto := canaryRolloutAt(1, deploymentRunning(newImage, 1, true), deploymentRunning(currentImage, 2, true))
+	auto.candidate.Annotations[rolloutStepTimeAnnotation] = time.Now().Add(-2 * time.Minute).Format(time.RFC3339)
+	if !auto.approved() {
+		t.Errorf("expected step to be approved once the candidate was ready for the interval")
+	}
+}
+
+func TestSameRouteWeight(t *testing.T) {
+	hundred, fifty := int32(100), int32(50)
+	cases := []struct {
+		wanted   *int32
+		existing *int32
+		same     bool
+	}{
+		{same: true},
+		{existing: &hundred, same: true},
+		{wanted: &fifty, existing: &fifty, same: true},
+		{wanted: &fifty, same: false},
+		{existing: &fifty, same: false},
+	}
+	for _, c := range cases {
+		if same := sameRouteWeight(c.wanted, c.existing); same != c.same {
+			t.Errorf("expected %v and %v weights sameness to be %t, got %t", c.wanted, c.existing, c.same, same)
+		}
+	}
+}
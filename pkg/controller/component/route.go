@@ -4,8 +4,10 @@ import (
 	routev1 "github.com/openshift/api/route/v1"
 	v1beta12 "halkyon.io/api/component/v1beta1"
 	"halkyon.io/operator-framework"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type route struct {
@@ -18,6 +20,7 @@ func newRoute(owner *v1beta12.Component) route {
 	config := framework.NewConfig(routev1.GroupVersion.WithKind("Route"))
 	config.Watched = framework.IsTargetClusterRunningOpenShift()
 	config.Created = owner.Spec.ExposeService && config.Watched
+	config.Updated = config.Created
 	return route{base: newConfiguredBaseDependent(owner, config)}
 }
 
@@ -32,13 +35,62 @@ func (res route) Build(empty bool) (runtime.Object, error) {
 			Namespace: c.Namespace,
 			Labels:    ls,
 		}
-		route.Spec = routev1.RouteSpec{
-			To: routev1.RouteTargetReference{
-				Kind: "Service",
-				Name: c.Name,
-			},
-		}
+		route.Spec = routeSpecFor(c)
 	}
 
 	return route, nil
 }
+
+// routeSpecFor returns the spec of a route exposing the service of the specified component. The traffic is only routed to
+// the main port of the service, so that other ports, e.g. the debug one, aren't reachable from outside the cluster.
+func routeSpecFor(c *v1beta12.Component) routev1.RouteSpec {
+	return routev1.RouteSpec{
+		To: routev1.RouteTargetReference{
+			Kind: "Service",
+			Name: c.Name,
+		},
+		Port: routePort(),
+	}
+}
+
+// routePort returns the port of the services traffic is routed to
+func routePort() *routev1.RoutePort {
+	return &routev1.RoutePort{TargetPort: intstr.FromString(mainPortName)}
+}
+
+// Update routes the configured share of the traffic to the candidate deployment of a canary rollout
+func (res route) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	c := res.ownerAsComponent()
+	route := toUpdate.(*routev1.Route)
+	r, err := currentRollout(c)
+	if err != nil {
+		return false, toUpdate, err
+	}
+	var alternates []routev1.RouteTargetReference
+	var weight *int32
+	if candidateWeight := r.canaryWeight(); candidateWeight > 0 {
+		mainWeight := 100 - candidateWeight
+		weight = &mainWeight
+		alternates = []routev1.RouteTargetReference{{Kind: "Service", Name: CandidateName(c), Weight: &candidateWeight}}
+	}
+	port := routePort()
+	if equality.Semantic.DeepEqual(alternates, route.Spec.AlternateBackends) && sameRouteWeight(weight, route.Spec.To.Weight) && equality.Semantic.DeepEqual(port, route.Spec.Port) {
+		return false, toUpdate, nil
+	}
+	route.Spec.Port = port
+	route.Spec.To.Weight = weight
+	route.Spec.AlternateBackends = alternates
+	return true, toUpdate, nil
+}
+
+// sameRouteWeight checks whether the specified route backend weights are equivalent, a missing weight defaulting to 100
+func sameRouteWeight(wanted, existing *int32) bool {
+	defaultWeight := int32(100)
+	if wanted == nil {
+		wanted = &defaultWeight
+	}
+	if existing == nil {
+		existing = &defaultWeight
+	}
+	return *wanted == *existing
+}
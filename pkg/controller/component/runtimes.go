@@ -5,8 +5,10 @@ import (
 	"halkyon.io/api/component/v1beta1"
 	"halkyon.io/api/runtime/clientset/versioned"
 	v1beta12 "halkyon.io/api/runtime/clientset/versioned/typed/runtime/v1beta1"
+	halkyonruntime "halkyon.io/api/runtime/v1beta1"
 	halkyon "halkyon.io/api/v1beta1"
 	framework "halkyon.io/operator-framework"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"strings"
 )
@@ -21,12 +23,15 @@ var runtimesClient v1beta12.RuntimeInterface
 type Runtime struct {
 	RegistryRef string
 	defaultEnv  map[string]string
+	resources   corev1.ResourceRequirements
+	probes      probesConfig
+	devMode     devModeConfig
 }
 
 func getImageInfo(component *v1beta1.Component) (Runtime, error) {
 	spec := component.Spec
 	if spec.Runtime == supervisorImageId {
-		return Runtime{RegistryRef: "quay.io/halkyonio/supervisord"}, nil
+		return Runtime{RegistryRef: "quay.io/halkyonio/supervisord", devMode: defaultDevModeConfig}, nil
 	}
 
 	if runtimesClient == nil {
@@ -59,6 +64,16 @@ func getImageInfo(component *v1beta1.Component) (Runtime, error) {
 				if len(envMap) > 0 {
 					runtime.defaultEnv = envMap
 				}
+				if _, err := unmarshalAnnotation(&item, ResourcesAnnotation, &runtime.resources); err != nil {
+					return Runtime{}, err
+				}
+				if _, err := unmarshalAnnotation(&item, ProbesAnnotation, &runtime.probes); err != nil {
+					return Runtime{}, err
+				}
+				// the dev mode configuration is only reported as invalid to the components using it
+				if runtime.devMode, err = getDevModeConfig(&item); err != nil && v1beta1.BuildDeploymentMode != component.Spec.DeploymentMode {
+					return Runtime{}, err
+				}
 
 				return runtime, nil
 			}
@@ -73,7 +88,45 @@ func getImageInfo(component *v1beta1.Component) (Runtime, error) {
 	return Runtime{}, fmt.Errorf("couldn't find '%s' runtime, known runtimes: %s", spec.Runtime, strings.Join(knownRuntimes, ","))
 }
 
-func getSupervisor() *v1beta1.Component {
+// getDevModeConfig returns the dev mode configuration the specified runtime declares with its DevModeAnnotation
+func getDevModeConfig(runtime *halkyonruntime.Runtime) (devModeConfig, error) {
+	devMode := devModeConfig{}
+	if _, err := unmarshalAnnotation(runtime, DevModeAnnotation, &devMode); err != nil {
+		return devMode, err
+	}
+	return devMode.withDefaults(runtime.Name)
+}
+
+// getResourceRequirements computes the resource requirements of the component's containers, resources specified on the
+// component overriding the defaults provided by its runtime
+func getResourceRequirements(component *v1beta1.Component, runtime Runtime) (corev1.ResourceRequirements, error) {
+	specified := corev1.ResourceRequirements{}
+	if _, err := unmarshalAnnotation(component, ResourcesAnnotation, &specified); err != nil {
+		return specified, err
+	}
+	return corev1.ResourceRequirements{
+		Requests: mergeResourceLists(runtime.resources.Requests, specified.Requests),
+		Limits:   mergeResourceLists(runtime.resources.Limits, specified.Limits),
+	}, nil
+}
+
+func mergeResourceLists(defaults, specified corev1.ResourceList) corev1.ResourceList {
+	if len(defaults) == 0 && len(specified) == 0 {
+		return nil
+	}
+	merged := make(corev1.ResourceList, len(defaults)+len(specified))
+	for name, quantity := range defaults {
+		merged[name] = quantity.DeepCopy()
+	}
+	for name, quantity := range specified {
+		merged[name] = quantity.DeepCopy()
+	}
+	return merged
+}
+
+// getSupervisor returns the component describing the init container copying supervisord, configured with the programs of
+// the specified dev mode configuration
+func getSupervisor(devMode devModeConfig) *v1beta1.Component {
 	return &v1beta1.Component{
 		ObjectMeta: v1.ObjectMeta{
 			Name: supervisorContainerName,
@@ -82,9 +135,8 @@ func getSupervisor() *v1beta1.Component {
 			Runtime: supervisorImageId,
 			Envs: []halkyon.NameValuePair{
 				{
-					Name: "CMDS",
-					Value: "build:/usr/local/bin/build:false;" +
-						"run:/usr/local/bin/run:true",
+					Name:  "CMDS",
+					Value: devMode.supervisorCommands(),
 				},
 			},
 		},
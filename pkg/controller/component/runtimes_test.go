@@ -0,0 +1,149 @@
+package component
+
+import (
+	"halkyon.io/api/component/v1beta1"
+	v1beta12 "halkyon.io/api/runtime/clientset/versioned/typed/runtime/v1beta1"
+	runtimev1beta1 "halkyon.io/api/runtime/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+// runtimesStub lists the specified runtimes, the other operations of the client being left unimplemented
+type runtimesStub struct {
+	v1beta12.RuntimeInterface
+	runtimes []runtimev1beta1.Runtime
+}
+
+func (s runtimesStub) List(opts metav1.ListOptions) (*runtimev1beta1.RuntimeList, error) {
+	return &runtimev1beta1.RuntimeList{Items: s.runtimes}, nil
+}
+
+// setRuntimes makes the specified runtimes the ones available on the cluster, returning a function restoring the client
+func setRuntimes(runtimes ...runtimev1beta1.Runtime) func() {
+	previous := runtimesClient
+	runtimesClient = runtimesStub{runtimes: runtimes}
+	return func() {
+		runtimesClient = previous
+	}
+}
+
+// springBootComponent returns a component using the runtime returned by springBootRuntime in the specified mode
+func springBootComponent(mode v1beta1.DeploymentMode) *v1beta1.Component {
+	c := &v1beta1.Component{ObjectMeta: metav1.ObjectMeta{Name: "fruits", Namespace: "demo"}}
+	c.Spec.Runtime = "spring-boot"
+	c.Spec.Version = "2.1.6.RELEASE"
+	c.Spec.Port = 8080
+	c.Spec.DeploymentMode = mode
+	return c
+}
+
+// springBootRuntime returns a Spring Boot runtime annotated with the specified annotations
+func springBootRuntime(annotations map[string]string) runtimev1beta1.Runtime {
+	runtime := runtimev1beta1.Runtime{ObjectMeta: metav1.ObjectMeta{Name: "spring-boot-2.1.6", Annotations: annotations}}
+	runtime.Spec.Name = "spring-boot"
+	runtime.Spec.Version = "2.1.6.RELEASE"
+	runtime.Spec.Image = "quay.io/halkyonio/hal-maven-jdk"
+	runtime.Spec.ExecutablePattern = "*.jar"
+	return runtime
+}
+
+// nodeRuntime returns a Node.js runtime debugged with the inspector
+func nodeRuntime() runtimev1beta1.Runtime {
+	runtime := runtimev1beta1.Runtime{ObjectMeta: metav1.ObjectMeta{Name: "nodejs-12", Annotations: map[string]string{DevModeAnnotation: `{"debugger":"inspector"}`}}}
+	runtime.Spec.Name = "nodejs"
+	runtime.Spec.Version = "12"
+	runtime.Spec.Image = "nodeshift/centos7-s2i-nodejs"
+	return runtime
+}
+
+func TestMergeResourceLists(t *testing.T) {
+	cases := []struct {
+		name      string
+		defaults  corev1.ResourceList
+		specified corev1.ResourceList
+		expected  corev1.ResourceList
+	}{
+		{name: "none"},
+		{
+			name:     "defaults only",
+			defaults: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			expected: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+		},
+		{
+			name:      "specified only",
+			specified: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+			expected:  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+		},
+		{
+			name:      "specified values win",
+			defaults:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi"), corev1.ResourceCPU: resource.MustParse("1")},
+			specified: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			expected:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi"), corev1.ResourceCPU: resource.MustParse("1")},
+		},
+	}
+	for _, c := range cases {
+		merged := mergeResourceLists(c.defaults, c.specified)
+		if c.expected == nil {
+			if merged != nil {
+				t.Errorf("%s: expected no resources, got %v", c.name, merged)
+			}
+			continue
+		}
+		if len(merged) != len(c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, merged)
+			continue
+		}
+		for name, quantity := range c.expected {
+			if merged[name].Cmp(quantity) != 0 {
+				t.Errorf("%s: expected %s %s, got %v", c.name, quantity.String(), name, merged[name])
+			}
+		}
+	}
+}
+
+func TestGetResourceRequirements(t *testing.T) {
+	runtime := Runtime{resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+	}}
+	cases := []struct {
+		name          string
+		annotation    string
+		requestMemory string
+		limitMemory   string
+		limitCPU      string
+		valid         bool
+	}{
+		{name: "runtime defaults", requestMemory: "256Mi", limitMemory: "512Mi", valid: true},
+		{name: "override", annotation: `{"limits":{"memory":"1Gi","cpu":"2"}}`, requestMemory: "256Mi", limitMemory: "1Gi", limitCPU: "2", valid: true},
+		{name: "invalid", annotation: `{"limits":{"memory":"lots"}}`, valid: false},
+	}
+	for _, c := range cases {
+		component := &v1beta1.Component{ObjectMeta: metav1.ObjectMeta{Name: "fruits"}}
+		if len(c.annotation) > 0 {
+			component.Annotations = map[string]string{ResourcesAnnotation: c.annotation}
+		}
+		requirements, err := getResourceRequirements(component, runtime)
+		if c.valid != (err == nil) {
+			t.Errorf("%s: expected validity to be %t, got error: %v", c.name, c.valid, err)
+			continue
+		}
+		if !c.valid {
+			continue
+		}
+		if memory := requirements.Requests[corev1.ResourceMemory]; memory.Cmp(resource.MustParse(c.requestMemory)) != 0 {
+			t.Errorf("%s: expected %s requested memory, got %s", c.name, c.requestMemory, memory.String())
+		}
+		if memory := requirements.Limits[corev1.ResourceMemory]; memory.Cmp(resource.MustParse(c.limitMemory)) != 0 {
+			t.Errorf("%s: expected %s memory limit, got %s", c.name, c.limitMemory, memory.String())
+		}
+		if cpu, ok := requirements.Limits[corev1.ResourceCPU]; ok != (len(c.limitCPU) > 0) || (ok && cpu.Cmp(resource.MustParse(c.limitCPU)) != 0) {
+			t.Errorf("%s: expected '%s' CPU limit, got %v", c.name, c.limitCPU, requirements.Limits)
+		}
+	}
+	if memory := runtime.resources.Limits[corev1.ResourceMemory]; memory.Cmp(resource.MustParse("512Mi")) != 0 {
+		t.Error("runtime defaults shouldn't be modified by merging")
+	}
+}
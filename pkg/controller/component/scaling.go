@@ -0,0 +1,132 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// scalingConfig defines how many replicas of a build mode component should be running
+type scalingConfig struct {
+	// Replicas is the fixed number of replicas to run, ignored if Autoscaling is specified
+	Replicas    *int32             `json:"replicas,omitempty"`
+	Autoscaling *autoscalingConfig `json:"autoscaling,omitempty"`
+	// MinAvailable and MaxUnavailable configure the generated PodDisruptionBudget, at most one can be specified
+	MinAvailable   *intstr.IntOrString `json:"minAvailable,omitempty"`
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// autoscalingConfig defines the bounds and metrics driving the HorizontalPodAutoscaler generated for a component
+type autoscalingConfig struct {
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	MaxReplicas int32  `json:"maxReplicas"`
+	// TargetCPUUtilization is the average CPU utilization percentage, relative to the requested CPU, to aim for
+	TargetCPUUtilization *int32 `json:"targetCPUUtilization,omitempty"`
+	// TargetMemoryUtilization is the average memory utilization percentage, relative to the requested memory, to aim for
+	TargetMemoryUtilization *int32 `json:"targetMemoryUtilization,omitempty"`
+	// Metrics holds additional, e.g. custom, metrics to scale on
+	Metrics []autoscalingv2beta1.MetricSpec `json:"metrics,omitempty"`
+}
+
+// getScalingConfig returns the scaling configuration of the specified component, which only applies in build mode
+func getScalingConfig(component *v1beta1.Component) (scalingConfig, error) {
+	scaling := scalingConfig{}
+	if v1beta1.BuildDeploymentMode != component.Spec.DeploymentMode {
+		return scaling, nil
+	}
+	if _, err := unmarshalAnnotation(component, ScalingAnnotation, &scaling); err != nil {
+		return scaling, err
+	}
+	if scaling.MinAvailable != nil && scaling.MaxUnavailable != nil {
+		return scaling, fmt.Errorf("only one of minAvailable or maxUnavailable can be specified in '%s' annotation", ScalingAnnotation)
+	}
+	if autoscaling := scaling.Autoscaling; autoscaling != nil {
+		if autoscaling.MaxReplicas < 1 {
+			return scaling, fmt.Errorf("maxReplicas must be specified for autoscaling in '%s' annotation", ScalingAnnotation)
+		}
+		if autoscaling.MinReplicas != nil && *autoscaling.MinReplicas > autoscaling.MaxReplicas {
+			return scaling, fmt.Errorf("minReplicas cannot be greater than maxReplicas in '%s' annotation", ScalingAnnotation)
+		}
+		if autoscaling.TargetCPUUtilization == nil && autoscaling.TargetMemoryUtilization == nil && len(autoscaling.Metrics) == 0 {
+			return scaling, fmt.Errorf("autoscaling requires at least one metric in '%s' annotation", ScalingAnnotation)
+		}
+	}
+	return scaling, nil
+}
+
+// isScaled returns whether the component is configured to run several replicas, in which case a PodDisruptionBudget is
+// needed
+func (in scalingConfig) isScaled() bool {
+	return in.Autoscaling != nil || (in.Replicas != nil && *in.Replicas > 1)
+}
+
+// desiredReplicas returns the number of replicas the deployment should specify, defaulting to a single one, nil if the
+// autoscaler is in charge
+func (in scalingConfig) desiredReplicas() *int32 {
+	if in.Autoscaling != nil {
+		return nil
+	}
+	if in.Replicas == nil {
+		one := int32(1)
+		return &one
+	}
+	return in.Replicas
+}
+
+// removeUnusedScalingResources deletes the HorizontalPodAutoscaler and PodDisruptionBudget of the specified component's
+// build deployment when its scaling configuration doesn't call for them anymore, since dependents which aren't created
+// aren't deleted either
+func removeUnusedScalingResources(c *v1beta1.Component) error {
+	scaling, err := getScalingConfig(c)
+	if err != nil {
+		return err
+	}
+	name := c.DeploymentNameFor(v1beta1.BuildDeploymentMode)
+	unused := make(map[string]runtime.Object, 2)
+	if scaling.Autoscaling == nil {
+		unused[hpaGVK.Kind] = &autoscalingv2beta1.HorizontalPodAutoscaler{}
+	}
+	if !scaling.isScaled() {
+		unused[pdbGVK.Kind] = &policyv1beta1.PodDisruptionBudget{}
+	}
+	for kind, object := range unused {
+		if _, err := framework.Helper.Fetch(name, c.Namespace, object); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if owned, ok := object.(metav1.Object); ok && !metav1.IsControlledBy(owned, c) {
+			continue
+		}
+		if err := framework.Helper.Client.Delete(context.TODO(), object); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("couldn't remove unused '%s' %s: %s", name, kind, err.Error())
+		}
+	}
+	return nil
+}
+
+// metrics returns the metrics the HorizontalPodAutoscaler should use
+func (in autoscalingConfig) metrics() []autoscalingv2beta1.MetricSpec {
+	metrics := make([]autoscalingv2beta1.MetricSpec, 0, len(in.Metrics)+2)
+	if in.TargetCPUUtilization != nil {
+		metrics = append(metrics, autoscalingv2beta1.MetricSpec{
+			Type:     autoscalingv2beta1.ResourceMetricSourceType,
+			Resource: &autoscalingv2beta1.ResourceMetricSource{Name: "cpu", TargetAverageUtilization: in.TargetCPUUtilization},
+		})
+	}
+	if in.TargetMemoryUtilization != nil {
+		metrics = append(metrics, autoscalingv2beta1.MetricSpec{
+			Type:     autoscalingv2beta1.ResourceMetricSourceType,
+			Resource: &autoscalingv2beta1.ResourceMetricSource{Name: "memory", TargetAverageUtilization: in.TargetMemoryUtilization},
+		})
+	}
+	return append(metrics, in.Metrics...)
+}
@@ -0,0 +1,139 @@
+package component
+
+import (
+	"halkyon.io/api/component/v1beta1"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"testing"
+)
+
+func scaledComponent(mode v1beta1.DeploymentMode, scaling string) *v1beta1.Component {
+	c := &v1beta1.Component{
+		ObjectMeta: metav1.ObjectMeta{Name: "fruits", Namespace: "demo"},
+		Spec:       v1beta1.ComponentSpec{DeploymentMode: mode},
+	}
+	if len(scaling) > 0 {
+		c.Annotations = map[string]string{ScalingAnnotation: scaling}
+	}
+	return c
+}
+
+func TestGetScalingConfig(t *testing.T) {
+	cases := []struct {
+		name     string
+		mode     v1beta1.DeploymentMode
+		scaling  string
+		replicas *int32
+		scaled   bool
+		valid    bool
+	}{
+		{name: "unset", mode: v1beta1.BuildDeploymentMode, replicas: int32Ptr(1), valid: true},
+		{name: "replicas", mode: v1beta1.BuildDeploymentMode, scaling: `{"replicas":3}`, replicas: int32Ptr(3), scaled: true, valid: true},
+		{name: "single replica", mode: v1beta1.BuildDeploymentMode, scaling: `{"replicas":1}`, replicas: int32Ptr(1), valid: true},
+		{name: "autoscaling", mode: v1beta1.BuildDeploymentMode, scaling: `{"replicas":3,"autoscaling":{"maxReplicas":5,"targetCPUUtilization":80}}`, scaled: true, valid: true},
+		{name: "ignored in dev mode", mode: v1beta1.DevDeploymentMode, scaling: `{"replicas":3}`, replicas: int32Ptr(1), valid: true},
+		{name: "both budgets", mode: v1beta1.BuildDeploymentMode, scaling: `{"replicas":3,"minAvailable":1,"maxUnavailable":1}`, valid: false},
+		{name: "no maxReplicas", mode: v1beta1.BuildDeploymentMode, scaling: `{"autoscaling":{"targetCPUUtilization":80}}`, valid: false},
+		{name: "min above max", mode: v1beta1.BuildDeploymentMode, scaling: `{"autoscaling":{"minReplicas":6,"maxReplicas":5,"targetCPUUtilization":80}}`, valid: false},
+		{name: "no metric", mode: v1beta1.BuildDeploymentMode, scaling: `{"autoscaling":{"maxReplicas":5}}`, valid: false},
+	}
+	for _, c := range cases {
+		scaling, err := getScalingConfig(scaledComponent(c.mode, c.scaling))
+		if c.valid != (err == nil) {
+			t.Errorf("%s: expected validity to be %t, got error: %v", c.name, c.valid, err)
+			continue
+		}
+		if !c.valid {
+			continue
+		}
+		replicas := scaling.desiredReplicas()
+		if (replicas == nil) != (c.replicas == nil) || (replicas != nil && *replicas != *c.replicas) {
+			t.Errorf("%s: expected %v replicas, got %v", c.name, c.replicas, replicas)
+		}
+		if scaling.isScaled() != c.scaled {
+			t.Errorf("%s: expected scaled to be %t", c.name, c.scaled)
+		}
+	}
+}
+
+func TestHorizontalPodAutoscalerSpec(t *testing.T) {
+	c := scaledComponent(v1beta1.BuildDeploymentMode, `{"autoscaling":{"minReplicas":2,"maxReplicas":5,"targetCPUUtilization":80,"targetMemoryUtilization":70}}`)
+	built, err := newHorizontalPodAutoscaler(c).Build(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := built.(*autoscalingv2beta1.HorizontalPodAutoscaler).Spec
+	if spec.ScaleTargetRef.Kind != "Deployment" || spec.ScaleTargetRef.Name != c.DeploymentName() {
+		t.Errorf("autoscaler should target '%s' deployment, got %v", c.DeploymentName(), spec.ScaleTargetRef)
+	}
+	if *spec.MinReplicas != 2 || spec.MaxReplicas != 5 {
+		t.Errorf("expected 2 to 5 replicas, got %d to %d", *spec.MinReplicas, spec.MaxReplicas)
+	}
+	if len(spec.Metrics) != 2 || spec.Metrics[0].Resource.Name != "cpu" || *spec.Metrics[0].Resource.TargetAverageUtilization != 80 ||
+		spec.Metrics[1].Resource.Name != "memory" || *spec.Metrics[1].Resource.TargetAverageUtilization != 70 {
+		t.Errorf("expected cpu and memory metrics, got %v", spec.Metrics)
+	}
+}
+
+func TestPodDisruptionBudgetSpec(t *testing.T) {
+	cases := []struct {
+		scaling        string
+		minAvailable   string
+		maxUnavailable string
+	}{
+		{scaling: `{"replicas":3}`, maxUnavailable: "1"},
+		{scaling: `{"autoscaling":{"maxReplicas":5,"targetCPUUtilization":80}}`, maxUnavailable: "1"},
+		{scaling: `{"replicas":3,"minAvailable":"50%"}`, minAvailable: "50%"},
+		{scaling: `{"replicas":3,"maxUnavailable":2}`, maxUnavailable: "2"},
+	}
+	for _, s := range cases {
+		c := scaledComponent(v1beta1.BuildDeploymentMode, s.scaling)
+		built, err := newPodDisruptionBudget(c).Build(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spec := built.(*policyv1beta1.PodDisruptionBudget).Spec
+		if spec.Selector.MatchLabels["app"] != c.DeploymentName() {
+			t.Errorf("'%s': budget should select the pods of '%s' deployment, got %v", s.scaling, c.DeploymentName(), spec.Selector)
+		}
+		if got := intOrStringValue(spec.MinAvailable); got != s.minAvailable {
+			t.Errorf("'%s': expected minAvailable '%s', got '%s'", s.scaling, s.minAvailable, got)
+		}
+		if got := intOrStringValue(spec.MaxUnavailable); got != s.maxUnavailable {
+			t.Errorf("'%s': expected maxUnavailable '%s', got '%s'", s.scaling, s.maxUnavailable, got)
+		}
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func intOrStringValue(value *intstr.IntOrString) string {
+	if value == nil {
+		return ""
+	}
+	return value.String()
+}
+
+func TestSameDisruptionBudget(t *testing.T) {
+	one, half := intstr.FromInt(1), intstr.FromString("50%")
+	cases := []struct {
+		name     string
+		wanted   policyv1beta1.PodDisruptionBudgetSpec
+		existing policyv1beta1.PodDisruptionBudgetSpec
+		same     bool
+	}{
+		{name: "unchanged", wanted: policyv1beta1.PodDisruptionBudgetSpec{MaxUnavailable: &one}, existing: policyv1beta1.PodDisruptionBudgetSpec{MaxUnavailable: &one}, same: true},
+		{name: "selector ignored", wanted: policyv1beta1.PodDisruptionBudgetSpec{MaxUnavailable: &one}, existing: policyv1beta1.PodDisruptionBudgetSpec{MaxUnavailable: &one, Selector: &metav1.LabelSelector{}}, same: true},
+		{name: "switched to minAvailable", wanted: policyv1beta1.PodDisruptionBudgetSpec{MinAvailable: &half}, existing: policyv1beta1.PodDisruptionBudgetSpec{MaxUnavailable: &one}, same: false},
+		{name: "changed value", wanted: policyv1beta1.PodDisruptionBudgetSpec{MaxUnavailable: &half}, existing: policyv1beta1.PodDisruptionBudgetSpec{MaxUnavailable: &one}, same: false},
+	}
+	for _, c := range cases {
+		if same := sameDisruptionBudget(c.wanted, c.existing); same != c.same {
+			t.Errorf("%s: expected budgets to be the same: %t, got %t", c.name, c.same, same)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package component
 
 import (
+	"fmt"
 	v1beta12 "halkyon.io/api/component/v1beta1"
+	beta1 "halkyon.io/api/v1beta1"
 	"halkyon.io/operator-framework"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type service struct {
@@ -33,33 +35,82 @@ func (res service) Build(empty bool) (runtime.Object, error) {
 			Namespace: c.Namespace,
 			Labels:    ls,
 		}
-		ser.Spec = corev1.ServiceSpec{
-			Selector: ls,
-			Type:     corev1.ServiceTypeClusterIP,
-			Ports: []corev1.ServicePort{
-				{
-					TargetPort: intstr.IntOrString{
-						Type:   intstr.Int,
-						IntVal: c.Spec.Port,
-					},
-					Port:     c.Spec.Port,
-					Protocol: "TCP",
-				},
-			},
-		}
+		ser.Spec = serviceSpecFor(c, ls)
 	}
 	return ser, nil
 }
 
+// serviceSpecFor returns the spec of a service exposing the port of the specified component on the pods matching selector
+func serviceSpecFor(c *v1beta12.Component, selector map[string]string) corev1.ServiceSpec {
+	return corev1.ServiceSpec{
+		Selector: selector,
+		Type:     corev1.ServiceTypeClusterIP,
+		Ports:    servicePortsFor(c),
+	}
+}
+
+// sameServicePorts checks whether the specified service ports expose the same ports, ignoring the defaulted fields
+func sameServicePorts(wanted, actual []corev1.ServicePort) bool {
+	if len(wanted) != len(actual) {
+		return false
+	}
+	for i := range wanted {
+		if wanted[i].Name != actual[i].Name || wanted[i].Port != actual[i].Port || wanted[i].TargetPort != actual[i].TargetPort || wanted[i].Protocol != actual[i].Protocol {
+			return false
+		}
+	}
+	return true
+}
+
 func (res service) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
 	c := res.ownerAsComponent()
 	svc := toUpdate.(*corev1.Service)
-	labels := getAppLabels(c)
-	if svc.Spec.Selector["app"] != labels["app"] {
-		for key, value := range labels {
+	r, err := currentRollout(c)
+	if err != nil {
+		return false, toUpdate, err
+	}
+	updated := false
+	if ports := servicePortsFor(c); !sameServicePorts(ports, svc.Spec.Ports) {
+		svc.Spec.Ports = ports
+		updated = true
+	}
+	target := r.serviceTarget(c)
+	if svc.Spec.Selector["app"] != target {
+		// only route the traffic to the deployment of the new mode once it can serve it
+		if target == c.DeploymentName() {
+			if canSwitch, err := canSwitchServiceTo(c, svc.Spec.Selector["app"]); err != nil || !canSwitch {
+				return updated, toUpdate, err
+			}
+		}
+		for key, value := range appLabelsFor(target) {
 			svc.Spec.Selector[key] = value
 		}
 		return true, toUpdate, nil
 	}
-	return false, toUpdate, nil
+	return updated, toUpdate, nil
+}
+
+func (res service) GetCondition(underlying runtime.Object, err error) *beta1.DependentCondition {
+	return framework.DefaultCustomizedGetConditionFor(res, err, underlying, func(underlying runtime.Object, cond *beta1.DependentCondition) {
+		c := res.ownerAsComponent()
+		current := underlying.(*corev1.Service).Spec.Selector["app"]
+		if current == CandidateName(c) {
+			cond.Message = fmt.Sprintf("traffic routed to '%s' deployment running the image being rolled out", current)
+			return
+		}
+		var previous *appsv1.Deployment
+		if current == c.DeploymentName() {
+			previous, _ = fetchPreviousModeDeployment(c)
+		}
+		switch modeSwitchStep(c, current, previous) {
+		case modeSwitchWaitingForDeployment:
+			cond.Type = beta1.DependentPending
+			cond.Reason = beta1.ReasonPending
+			cond.Message = fmt.Sprintf("switching to %s mode: waiting for '%s' deployment to be available, traffic still routed to '%s'", c.Spec.DeploymentMode, c.DeploymentName(), current)
+			cond.SetAttribute(ModeSwitchAttributeKey, modeSwitchWaitingForDeployment)
+		case modeSwitchRemovingPrevious:
+			cond.Message = fmt.Sprintf("switched to %s mode: removing '%s' deployment", c.Spec.DeploymentMode, previous.Name)
+			cond.SetAttribute(ModeSwitchAttributeKey, modeSwitchRemovingPrevious)
+		}
+	})
 }
@@ -1,9 +1,13 @@
 package component
 
 import (
+	"context"
+	"encoding/json"
 	v1beta12 "halkyon.io/api/component/v1beta1"
 	framework "halkyon.io/operator-framework"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"sort"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,7 +20,9 @@ type serviceAccount struct {
 var _ framework.DependentResource = &serviceAccount{}
 
 func newServiceAccount(owner *v1beta12.Component) serviceAccount {
-	s := serviceAccount{base: newBaseDependent(&corev1.ServiceAccount{}, owner)}
+	config := framework.NewConfig(corev1.SchemeGroupVersion.WithKind("ServiceAccount"))
+	config.Updated = true
+	s := serviceAccount{base: newConfiguredBaseDependent(owner, config)}
 	s.NameFn = s.Name
 	return s
 }
@@ -32,10 +38,192 @@ func (res serviceAccount) Build(empty bool) (runtime.Object, error) {
 			Namespace: c.Namespace,
 			Labels:    ls,
 		}
+		res.linkSecrets(sa)
 	}
 	return sa, nil
 }
 
+// Update links the registry and git secrets of the component to the service account, which being shared by all the
+// components of the namespace, keeps secrets linked for other components
+func (res serviceAccount) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	sa := toUpdate.(*corev1.ServiceAccount)
+	return res.linkSecrets(sa), sa, nil
+}
+
+// linkedSecretsAnnotation records, on the shared build service account, the secrets linked for each component so that
+// the secrets no component references anymore can be unlinked
+const linkedSecretsAnnotation = "halkyon.io/linked-secrets"
+
+// linkedSecrets holds the names of the secrets, and image pull secrets, linked to the build service account for a component
+type linkedSecrets struct {
+	Secrets     []string `json:"secrets,omitempty"`
+	PullSecrets []string `json:"pullSecrets,omitempty"`
+}
+
+// linkSecrets links the registry secret of the component, if any, as secret and image pull secret of the specified
+// service account and its git secret, if any, as secret, unlinking the secrets it doesn't reference anymore, and returns
+// whether the service account was modified
+func (res serviceAccount) linkSecrets(sa *corev1.ServiceAccount) bool {
+	c := res.ownerAsComponent()
+	wanted := linkedSecrets{}
+	// configuration errors are reported when checking the component's validity
+	if registry, _ := getRegistryConfig(c); len(registry.Secret) > 0 {
+		wanted.Secrets = append(wanted.Secrets, registry.Secret)
+		wanted.PullSecrets = append(wanted.PullSecrets, registry.Secret)
+	}
+	if git, _ := getGitConfig(c); len(git.Secret) > 0 {
+		wanted.Secrets = append(wanted.Secrets, git.Secret)
+	}
+	return setLinkedSecrets(sa, c.Name, wanted)
+}
+
+// setLinkedSecrets records the secrets linked for the specified component and updates the secrets of the given service
+// account accordingly: the secrets components need are linked while the ones previously linked for components which don't
+// reference them anymore are unlinked, secrets linked by other means being left alone. Returns whether the service account
+// was modified.
+func setLinkedSecrets(sa *corev1.ServiceAccount, component string, wanted linkedSecrets) bool {
+	linked := map[string]linkedSecrets{}
+	if value, ok := sa.Annotations[linkedSecretsAnnotation]; ok {
+		// a corrupted record is simply started over
+		_ = json.Unmarshal([]byte(value), &linked)
+	}
+	previousSecrets, previousPullSecrets := linkedSecretNames(linked)
+	if len(wanted.Secrets) == 0 && len(wanted.PullSecrets) == 0 {
+		delete(linked, component)
+	} else {
+		linked[component] = wanted
+	}
+	secrets, pullSecrets := linkedSecretNames(linked)
+
+	updated := false
+	kept := make([]corev1.ObjectReference, 0, len(sa.Secrets)+len(secrets))
+	for _, secret := range sa.Secrets {
+		if previousSecrets[secret.Name] && !secrets[secret.Name] {
+			updated = true
+			continue
+		}
+		kept = append(kept, secret)
+	}
+	for _, name := range sortedNames(secrets) {
+		if !containsSecret(kept, name) {
+			kept = append(kept, corev1.ObjectReference{Name: name})
+			updated = true
+		}
+	}
+	keptPull := make([]corev1.LocalObjectReference, 0, len(sa.ImagePullSecrets)+len(pullSecrets))
+	for _, secret := range sa.ImagePullSecrets {
+		if previousPullSecrets[secret.Name] && !pullSecrets[secret.Name] {
+			updated = true
+			continue
+		}
+		keptPull = append(keptPull, secret)
+	}
+	for _, name := range sortedNames(pullSecrets) {
+		if !containsPullSecret(keptPull, name) {
+			keptPull = append(keptPull, corev1.LocalObjectReference{Name: name})
+			updated = true
+		}
+	}
+	if updated {
+		sa.Secrets, sa.ImagePullSecrets = kept, keptPull
+	}
+
+	value := ""
+	if len(linked) > 0 {
+		encoded, _ := json.Marshal(linked)
+		value = string(encoded)
+	}
+	if sa.Annotations[linkedSecretsAnnotation] != value {
+		if len(value) == 0 {
+			delete(sa.Annotations, linkedSecretsAnnotation)
+		} else {
+			if sa.Annotations == nil {
+				sa.Annotations = make(map[string]string, 1)
+			}
+			sa.Annotations[linkedSecretsAnnotation] = value
+		}
+		updated = true
+	}
+	return updated
+}
+
+// linkedSecretNames returns the names of the secrets, and image pull secrets, linked for any of the specified components
+func linkedSecretNames(linked map[string]linkedSecrets) (secrets, pullSecrets map[string]bool) {
+	secrets, pullSecrets = make(map[string]bool, len(linked)), make(map[string]bool, len(linked))
+	for _, component := range linked {
+		for _, name := range component.Secrets {
+			secrets[name] = true
+		}
+		for _, name := range component.PullSecrets {
+			pullSecrets[name] = true
+		}
+	}
+	return secrets, pullSecrets
+}
+
+func sortedNames(names map[string]bool) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// unlinkSecrets unlinks the secrets linked to the build service accounts for the specified component when it is deleted,
+// unless other components reference them
+func unlinkSecrets(c *v1beta12.Component) error {
+	for _, name := range []string{privilegedBuildServiceAccount, rootlessBuildServiceAccount} {
+		if err := unlinkSecretsFrom(name, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unlinkUnusedSecrets unlinks the secrets linked for the specified component to the build service account it doesn't
+// use anymore, if it switched between privileged and rootless builds
+func unlinkUnusedSecrets(c *v1beta12.Component) error {
+	unused := privilegedBuildServiceAccount
+	if ServiceAccountName(c) == privilegedBuildServiceAccount {
+		unused = rootlessBuildServiceAccount
+	}
+	return unlinkSecretsFrom(unused, c)
+}
+
+// unlinkSecretsFrom unlinks the secrets linked for the specified component to the service account with the given name
+func unlinkSecretsFrom(name string, c *v1beta12.Component) error {
+	sa := &corev1.ServiceAccount{}
+	if _, err := framework.Helper.Fetch(name, c.Namespace, sa); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if setLinkedSecrets(sa, c.Name, linkedSecrets{}) {
+		return framework.Helper.Client.Update(context.TODO(), sa)
+	}
+	return nil
+}
+
+func containsSecret(secrets []corev1.ObjectReference, name string) bool {
+	for _, secret := range secrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPullSecret(secrets []corev1.LocalObjectReference, name string) bool {
+	for _, secret := range secrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (res serviceAccount) Name() string {
-	return ServiceAccountName(res.Owner())
+	return ServiceAccountName(res.ownerAsComponent())
 }
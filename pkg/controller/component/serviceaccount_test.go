@@ -0,0 +1,58 @@
+package component
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+func secretNames(sa *corev1.ServiceAccount) (secrets, pullSecrets []string) {
+	for _, secret := range sa.Secrets {
+		secrets = append(secrets, secret.Name)
+	}
+	for _, secret := range sa.ImagePullSecrets {
+		pullSecrets = append(pullSecrets, secret.Name)
+	}
+	return secrets, pullSecrets
+}
+
+func TestSetLinkedSecrets(t *testing.T) {
+	sa := &corev1.ServiceAccount{
+		Secrets:          []corev1.ObjectReference{{Name: "build-bot-token-x2v4s"}},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "build-bot-dockercfg-9k7qz"}},
+	}
+
+	if !setLinkedSecrets(sa, "frontend", linkedSecrets{Secrets: []string{"quay", "github"}, PullSecrets: []string{"quay"}}) {
+		t.Fatal("linking new secrets should update the service account")
+	}
+	if !setLinkedSecrets(sa, "backend", linkedSecrets{Secrets: []string{"quay"}, PullSecrets: []string{"quay"}}) {
+		t.Fatal("recording the secrets of another component should update the service account")
+	}
+	if setLinkedSecrets(sa, "backend", linkedSecrets{Secrets: []string{"quay"}, PullSecrets: []string{"quay"}}) {
+		t.Error("linking the same secrets again shouldn't update the service account")
+	}
+	secrets, pullSecrets := secretNames(sa)
+	if len(secrets) != 3 || len(pullSecrets) != 2 {
+		t.Fatalf("expected token, github and quay secrets and dockercfg and quay pull secrets, got %v and %v", secrets, pullSecrets)
+	}
+
+	// frontend stops using its registry and git secrets, the registry secret remaining linked for backend
+	if !setLinkedSecrets(sa, "frontend", linkedSecrets{}) {
+		t.Fatal("unlinking secrets should update the service account")
+	}
+	secrets, pullSecrets = secretNames(sa)
+	if len(secrets) != 2 || secrets[0] != "build-bot-token-x2v4s" || secrets[1] != "quay" {
+		t.Errorf("expected github secret to be unlinked, got %v", secrets)
+	}
+	if len(pullSecrets) != 2 {
+		t.Errorf("expected quay pull secret to remain linked for backend, got %v", pullSecrets)
+	}
+
+	setLinkedSecrets(sa, "backend", linkedSecrets{})
+	secrets, pullSecrets = secretNames(sa)
+	if len(secrets) != 1 || len(pullSecrets) != 1 || secrets[0] != "build-bot-token-x2v4s" || pullSecrets[0] != "build-bot-dockercfg-9k7qz" {
+		t.Errorf("only the secrets which weren't linked by the operator should remain, got %v and %v", secrets, pullSecrets)
+	}
+	if _, ok := sa.Annotations[linkedSecretsAnnotation]; ok {
+		t.Errorf("linked secrets record should be removed once empty, got %v", sa.Annotations)
+	}
+}
@@ -1,15 +1,21 @@
 package component
 
 import (
+	"context"
+	"fmt"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 	"halkyon.io/api/component/v1beta1"
 	"halkyon.io/operator-framework"
-	"halkyon.io/operator-framework/util"
-	corev1 "k8s.io/api/core/v1"
+	tektonv1beta1 "halkyon.io/operator/pkg/apis/tekton/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// legacyTaskName is the name of the Task shared by the components of a namespace before each component got its own Task
+const legacyTaskName = "s2i-buildah-push"
+
 type task struct {
 	base
 }
@@ -17,8 +23,9 @@ type task struct {
 var _ framework.DependentResource = &task{}
 
 func newTask(owner *v1beta1.Component) task {
-	config := framework.NewConfig(v1alpha1.SchemeGroupVersion.WithKind("Task"))
-	config.CheckedForReadiness = v1beta1.BuildDeploymentMode == owner.Spec.DeploymentMode
+	config := framework.NewConfig(tektonGroupVersion().WithKind("Task"))
+	config.Watched = isTektonAvailable()
+	config.CheckedForReadiness = v1beta1.BuildDeploymentMode == owner.Spec.DeploymentMode && !usesJobExecutor()
 	config.Created = config.CheckedForReadiness
 	config.Updated = config.CheckedForReadiness
 	t := task{base: newConfiguredBaseDependent(owner, config)}
@@ -27,139 +34,104 @@ func newTask(owner *v1beta1.Component) task {
 }
 
 func (res task) Build(empty bool) (runtime.Object, error) {
-	task := &v1alpha1.Task{}
-	if !empty {
-		c := res.ownerAsComponent()
-		task.ObjectMeta = metav1.ObjectMeta{
-			Namespace: c.Namespace,
-			Name:      res.Name(),
+	if empty {
+		if usesTektonV1beta1() {
+			return &tektonv1beta1.Task{}, nil
 		}
-		task.Spec = v1alpha1.TaskSpec{
-			Inputs: &v1alpha1.Inputs{
-				// This input corresponds to a pre-task step as the project will be cloned
-				// under by default the following directory : /workspace/{resource-name}
-				// Resource name has been defined to git hereafter
-				Resources: []v1alpha1.TaskResource{{
-					ResourceDeclaration: v1alpha1.ResourceDeclaration{
-						Name: "git",
-						Type: "git",
-					},
-				}},
-				Params: []v1alpha1.ParamSpec{
-					{Name: "baseImage", Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: "quay.io/halkyonio/spring-boot-maven-s2i"}, Description: "S2i base image"},
-					{Name: "contextPath", Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: "."}, Description: "The location of the path to run s2i from"},
-					{Name: "moduleDirName", Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: "."}, Description: "The name of the directory containing the project (maven, ...) to be compiled"},
-					{Name: "verifyTLS", Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: "false"}, Description: "Verify registry certificates"},
-					{Name: "workspacePath", Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: "/workspace/git"}, Description: "Git path where project is cloned"},
-				},
-			},
-			Outputs: &v1alpha1.Outputs{
-				Resources: []v1alpha1.TaskResource{{
-					ResourceDeclaration: v1alpha1.ResourceDeclaration{
-						Name: "image",
-						Type: "image",
-					},
-				}},
-			},
-			Steps: []v1alpha1.Step{
-				{Container: corev1.Container{
-					// # Generate a Dockerfile using the s2i tool
-					Name:  "generate",
-					Image: "quay.io/openshift-pipeline/s2i",
-					Command: []string{
-						"s2i",
-						"build",
-					},
-					Args: []string{
-						"$(inputs.params.workspacePath)/$(inputs.params.contextPath)",
-						"$(inputs.params.baseImage)",
-						"--as-dockerfile",
-						"/sources/Dockerfile.gen",
-						"--image-scripts-url",
-						"image:///usr/local/s2i",
-						"--loglevel",
-						"5",
-						"--env",
-						"MAVEN_ARGS_APPEND=-pl $(inputs.params.moduleDirName)",
-						"--env",
-						"MAVEN_S2I_ARTIFACT_DIRS=$(inputs.params.moduleDirName)/target",
-						"--env",
-						"S2I_SOURCE_DEPLOYMENTS_FILTER=*.jar",
-					},
-					VolumeMounts: []corev1.VolumeMount{
-						{
-							MountPath: "/sources",
-							Name:      "generatedsources"},
-					},
-				}},
-				{Container: corev1.Container{
-					// Build a Container image using the dockerfile created previously
-					Name:       "build",
-					Image:      "quay.io/buildah/stable:v1.9.0",
-					WorkingDir: "/sources",
-					Command: []string{
-						"buildah",
-					},
-					Args: []string{
-						"bud",
-						"--tls-verify=$(inputs.params.verifyTLS)",
-						"--layers",
-						"-f",
-						"/sources/Dockerfile.gen",
-						"-t",
-						"$(outputs.resources.image.url)",
-						"."},
-					VolumeMounts: []corev1.VolumeMount{
-						{
-							Name:      "libcontainers",
-							MountPath: "/var/lib/containers",
-						},
-						{
-							Name:      "generatedsources",
-							MountPath: "/sources",
-						},
-					},
-					SecurityContext: &corev1.SecurityContext{
-						Privileged: util.NewTrue(),
-					},
-				}},
-				{Container: corev1.Container{
-					// Push the image created to quay.io using as credentials the secret mounted within
-					// the service account
-					Name:  "push",
-					Image: "quay.io/buildah/stable:v1.9.0",
-					Command: []string{
-						"buildah",
-					},
-					Env: []corev1.EnvVar{
-						{Name: "REGISTRY_AUTH_FILE", Value: "/home/builder/.docker/config.json"},
-					},
-					Args: []string{
-						"push",
-						"--tls-verify=$(inputs.params.verifyTLS)",
-						"$(outputs.resources.image.url)",
-						"docker://$(outputs.resources.image.url)",
-					},
-					VolumeMounts: []corev1.VolumeMount{
-						{
-							MountPath: "/var/lib/containers",
-							Name:      "libcontainers"},
-					},
-					SecurityContext: &corev1.SecurityContext{
-						Privileged: util.NewTrue(),
-					},
-				}},
-			},
-			Volumes: []corev1.Volume{
-				{Name: "generatedsources", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
-				{Name: "libcontainers", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
-			},
+		return &v1alpha1.Task{}, nil
+	}
+
+	c := res.ownerAsComponent()
+	meta := metav1.ObjectMeta{
+		Namespace: c.Namespace,
+		Name:      res.Name(),
+		Labels:    getBuildLabels(c.Name),
+	}
+	spec, err := taskSpecFor(c)
+	if err != nil {
+		return nil, err
+	}
+	if usesTektonV1beta1() {
+		return &tektonv1beta1.Task{ObjectMeta: meta, Spec: toV1beta1TaskSpec(spec)}, nil
+	}
+	return &v1alpha1.Task{ObjectMeta: meta, Spec: spec}, nil
+}
+
+func (res task) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	wanted, err := taskSpecFor(res.ownerAsComponent())
+	if err != nil {
+		return false, toUpdate, err
+	}
+	switch t := toUpdate.(type) {
+	case *tektonv1beta1.Task:
+		if converted := toV1beta1TaskSpec(wanted); !equality.Semantic.DeepEqual(converted, t.Spec) {
+			t.Spec = converted
+			return true, t, nil
+		}
+	case *v1alpha1.Task:
+		if !equality.Semantic.DeepEqual(wanted, t.Spec) {
+			t.Spec = wanted
+			return true, t, nil
 		}
 	}
+	return false, toUpdate, nil
+}
 
-	return task, nil
+// taskSpecFor returns the spec of the Task building the specified component, configured to access its registry and use
+// its build cache
+func taskSpecFor(c *v1beta1.Component) (v1alpha1.TaskSpec, error) {
+	strategy, err := buildStrategyFor(c)
+	if err != nil {
+		return v1alpha1.TaskSpec{}, err
+	}
+	registry, err := getRegistryConfig(c)
+	if err != nil {
+		return v1alpha1.TaskSpec{}, err
+	}
+	cache, cached, err := getBuildCacheConfig(c)
+	if err != nil {
+		return v1alpha1.TaskSpec{}, err
+	}
+	rootless, err := isRootlessBuild(c)
+	if err != nil {
+		return v1alpha1.TaskSpec{}, err
+	}
+	spec := strategy.taskSpec()
+	if rootless {
+		makeRootless(&spec, !framework.IsTargetClusterRunningOpenShift())
+	}
+	registry.configure(&spec, dockerImageURL(c))
+	if cached {
+		cache.configure(&spec, cache.name(c))
+	}
+	return spec, nil
+}
+
+// removeLegacyTask deletes the Task the builds of the specified component used before it got its own Task, if the
+// component created it. Components which didn't create it still use it and leave it to its owner.
+func removeLegacyTask(c *v1beta1.Component) error {
+	if !isTektonAvailable() {
+		return nil
+	}
+	legacy, err := newTask(c).Build(true)
+	if err != nil {
+		return err
+	}
+	if _, err := framework.Helper.Fetch(legacyTaskName, c.Namespace, legacy); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if owned, ok := legacy.(metav1.Object); !ok || !metav1.IsControlledBy(owned, c) {
+		return nil
+	}
+	if err := framework.Helper.Client.Delete(context.TODO(), legacy); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("couldn't remove legacy '%s' Task: %s", legacyTaskName, err.Error())
+	}
+	return nil
 }
 
 func (res task) Name() string {
-	return TaskName(res.Owner())
+	return TaskName(res.ownerAsComponent())
 }
@@ -0,0 +1,95 @@
+package component
+
+import (
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setTektonAvailable skips the detection of Tekton, which needs a cluster, and makes it report the specified availability
+func setTektonAvailable(available bool) {
+	tektonAPI.available = available
+	tektonAPI.version = v1alpha1.SchemeGroupVersion
+	tektonAPI.next = time.Now().Add(time.Hour)
+}
+
+func hasVolume(pod corev1.PodSpec, name string) bool {
+	for _, volume := range pod.Volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func buildComponent(annotations map[string]string) *v1beta1.Component {
+	c := &v1beta1.Component{ObjectMeta: metav1.ObjectMeta{Name: "fruits", Namespace: "demo", Annotations: annotations}}
+	c.Spec.DeploymentMode = v1beta1.BuildDeploymentMode
+	return c
+}
+
+func stepNames(spec v1alpha1.TaskSpec) []string {
+	names := make([]string, 0, len(spec.Steps))
+	for _, step := range spec.Steps {
+		names = append(names, step.Name)
+	}
+	return names
+}
+
+func TestTaskSpecFor(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		steps       []string
+		volume      string
+		valid       bool
+	}{
+		{name: "default", steps: []string{"generate", "build", "push"}, valid: true},
+		{name: "cached", annotations: map[string]string{BuildCacheAnnotation: "{}"}, steps: []string{"prepare-cache", "generate", "build", "push", "report-cache"}, volume: buildCacheVolume, valid: true},
+		{name: "registry secret", annotations: map[string]string{RegistryAnnotation: `{"secret":"registry-credentials"}`}, steps: []string{"generate", "build", "push"}, volume: registryCredentialsVolume, valid: true},
+		{name: "invalid cache", annotations: map[string]string{BuildCacheAnnotation: `{"scope":"cluster"}`}, valid: false},
+	}
+	for _, c := range cases {
+		spec, err := taskSpecFor(buildComponent(c.annotations))
+		if c.valid != (err == nil) {
+			t.Errorf("%s: expected validity to be %t, got error: %v", c.name, c.valid, err)
+			continue
+		}
+		if !c.valid {
+			continue
+		}
+		if names := stepNames(spec); !reflect.DeepEqual(names, c.steps) {
+			t.Errorf("%s: expected %v steps, got %v", c.name, c.steps, names)
+		}
+		if len(c.volume) > 0 && !hasVolume(corev1.PodSpec{Volumes: spec.Volumes}, c.volume) {
+			t.Errorf("%s: expected '%s' volume, got %+v", c.name, c.volume, spec.Volumes)
+		}
+	}
+}
+
+func TestTaskUpdate(t *testing.T) {
+	setTektonAvailable(true)
+	c := buildComponent(nil)
+	wanted, err := taskSpecFor(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := newTask(c)
+	existing := &v1alpha1.Task{ObjectMeta: metav1.ObjectMeta{Name: res.Name(), Namespace: c.Namespace}, Spec: wanted}
+	if updated, _, err := res.Update(existing); err != nil || updated {
+		t.Errorf("expected up to date Task not to be updated, got %t and error: %v", updated, err)
+	}
+
+	c.Annotations = map[string]string{BuildCacheAnnotation: "{}"}
+	updated, object, err := newTask(c).Update(existing)
+	if err != nil || !updated {
+		t.Fatalf("expected Task to be updated when the build cache is enabled, got %t and error: %v", updated, err)
+	}
+	if spec := object.(*v1alpha1.Task).Spec; !hasVolume(corev1.PodSpec{Volumes: spec.Volumes}, buildCacheVolume) {
+		t.Errorf("expected updated Task to use the build cache, got %+v volumes", spec.Volumes)
+	}
+}
@@ -1,14 +1,40 @@
 package component
 
 import (
+	"context"
 	"fmt"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 	"halkyon.io/api/component/v1beta1"
 	beta1 "halkyon.io/api/v1beta1"
 	"halkyon.io/operator-framework"
+	tektonv1beta1 "halkyon.io/operator/pkg/apis/tekton/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+)
+
+// Attributes recorded on the TaskRun condition to describe the latest build
+const (
+	BuildNameAttributeKey     = "BuildName"
+	BuildRevisionAttributeKey = "BuildRevision"
+	BuildImageAttributeKey    = "BuildImage"
+	BuildDigestAttributeKey   = "BuildDigest"
+	// BuildExecutorAttributeKey records whether builds are run by Tekton or as Jobs
+	BuildExecutorAttributeKey = "BuildExecutor"
+	// BuildFailedStepAttributeKey and BuildLogAttributeKey identify the step that made the build fail and the end of its log
+	BuildFailedStepAttributeKey = "BuildFailedStep"
+	BuildLogAttributeKey        = "BuildLog"
+	// BuildStagesAttributeKey and BuildFailedStageAttributeKey report the status of the stages of pipeline builds and the
+	// stage that made the pipeline fail
+	BuildStagesAttributeKey      = "BuildStages"
+	BuildFailedStageAttributeKey = "BuildFailedStage"
+	// BuildCacheUsageAttributeKey records the usage of the build cache as reported by the latest build
+	BuildCacheUsageAttributeKey = "BuildCacheUsage"
 )
 
 type taskRun struct {
@@ -18,50 +44,153 @@ type taskRun struct {
 var _ framework.DependentResource = &taskRun{}
 
 func newTaskRun(owner *v1beta1.Component) taskRun {
-	config := framework.NewConfig(v1alpha1.SchemeGroupVersion.WithKind("TaskRun"))
+	gvk := tektonGroupVersion().WithKind("TaskRun")
+	if usesJobExecutor() {
+		gvk = batchv1.SchemeGroupVersion.WithKind("Job")
+	} else if usesPipeline(owner) {
+		gvk = tektonGroupVersion().WithKind("PipelineRun")
+	}
+	config := framework.NewConfig(gvk)
 	config.CheckedForReadiness = v1beta1.BuildDeploymentMode == owner.Spec.DeploymentMode
 	config.Created = config.CheckedForReadiness
 	config.Updated = config.CheckedForReadiness
-	return taskRun{base: newConfiguredBaseDependent(owner, config)}
+	t := taskRun{base: newConfiguredBaseDependent(owner, config)}
+	t.NameFn = t.Name
+	return t
+}
+
+// Name returns a name identifying the build inputs so that a new TaskRun gets created whenever they change
+func (res taskRun) Name() string {
+	return BuildName(res.ownerAsComponent())
 }
 
 func (res taskRun) Build(empty bool) (runtime.Object, error) {
-	taskRun := &v1alpha1.TaskRun{}
-	if !empty {
-		c := res.ownerAsComponent()
-		ls := getBuildLabels(c.Name)
-		taskRun.ObjectMeta = metav1.ObjectMeta{
-			Namespace: c.Namespace,
-			Name:      res.Name(),
-			Labels:    ls,
+	if empty {
+		return emptyBuildRun(res.ownerAsComponent()), nil
+	}
+	c := res.ownerAsComponent()
+	strategy, err := buildStrategyFor(c)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := getRegistryConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	git, err := getGitConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	meta := metav1.ObjectMeta{
+		Namespace: c.Namespace,
+		Name:      res.Name(),
+		Labels:    getBuildLabels(c.Name),
+	}
+	// See description of the parameters within the Tasks
+	// We only override parameters here. Defaults are defined within the Tasks
+	params := append(strategy.taskRunParams(c), stringParam("verifyTLS", registry.verifyTLS()))
+	if usesJobExecutor() {
+		spec, err := taskSpecFor(c)
+		if err != nil {
+			return nil, err
+		}
+		return buildJob(c, meta, spec, params, git), nil
+	}
+	if usesPipeline(c) {
+		return res.buildPipelineRun(c, meta, params, git), nil
+	}
+	if usesTektonV1beta1() {
+		return res.buildV1beta1(c, meta, params, git), nil
+	}
+	return res.buildV1alpha1(c, meta, params, git), nil
+}
+
+// buildV1beta1 creates a TaskRun passing the git repository and image URL as parameters, cloning the sources into an
+// ephemeral workspace
+func (res taskRun) buildV1beta1(c *v1beta1.Component, meta metav1.ObjectMeta, params []v1alpha1.Param, git gitConfig) runtime.Object {
+	return &tektonv1beta1.TaskRun{
+		ObjectMeta: meta,
+		Spec: tektonv1beta1.TaskRunSpec{
+			ServiceAccountName: ServiceAccountName(c),
+			TaskRef: &v1alpha1.TaskRef{
+				Name: TaskName(c),
+			},
+			Params: sourceParams(c, params, git),
+			Workspaces: []tektonv1beta1.WorkspaceBinding{{
+				Name:     sourceWorkspace,
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			}},
+		},
+	}
+}
+
+// buildPipelineRun creates a PipelineRun of the component's Pipeline, passing the same parameters as v1beta1 TaskRuns,
+// each stage cloning the sources into its own ephemeral workspace
+func (res taskRun) buildPipelineRun(c *v1beta1.Component, meta metav1.ObjectMeta, params []v1alpha1.Param, git gitConfig) runtime.Object {
+	return &tektonv1beta1.PipelineRun{
+		ObjectMeta: meta,
+		Spec: tektonv1beta1.PipelineRunSpec{
+			ServiceAccountName: ServiceAccountName(c),
+			PipelineRef: &tektonv1beta1.PipelineRef{
+				Name: PipelineName(c),
+			},
+			Params: sourceParams(c, params, git),
+			Workspaces: []tektonv1beta1.WorkspaceBinding{{
+				Name:     sourceWorkspace,
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			}},
+		},
+	}
+}
+
+// sourceParams adds the parameters identifying the git repository and the image to the specified build parameters
+func sourceParams(c *v1beta1.Component, params []v1alpha1.Param, git gitConfig) []v1alpha1.Param {
+	params = append(params,
+		stringParam("gitUrl", c.Spec.BuildConfig.URL),
+		stringParam("gitRevision", GitRevision(c)),
+		stringParam("image", dockerImageURL(c)),
+	)
+	extraGitParams := git.resourceParams()
+	for _, name := range []string{"depth", "submodules"} {
+		if value, ok := extraGitParams[name]; ok {
+			params = append(params, stringParam(name, value))
+		}
+	}
+	return params
+}
+
+// buildV1alpha1 creates a TaskRun using git and image PipelineResources
+func (res taskRun) buildV1alpha1(c *v1beta1.Component, meta metav1.ObjectMeta, params []v1alpha1.Param, git gitConfig) runtime.Object {
+	gitParams := []v1alpha1.ResourceParam{
+		{
+			Name:  "revision",
+			Value: GitRevision(c),
+		},
+		{
+			Name:  "url",
+			Value: c.Spec.BuildConfig.URL},
+	}
+	extraGitParams := git.resourceParams()
+	for _, name := range []string{"depth", "submodules"} {
+		if value, ok := extraGitParams[name]; ok {
+			gitParams = append(gitParams, v1alpha1.ResourceParam{Name: name, Value: value})
 		}
-		taskRun.Spec = v1alpha1.TaskRunSpec{
+	}
+	return &v1alpha1.TaskRun{
+		ObjectMeta: meta,
+		Spec: v1alpha1.TaskRunSpec{
 			ServiceAccountName: ServiceAccountName(c),
 			TaskRef: &v1alpha1.TaskRef{
 				Name: TaskName(c),
 			},
 			Inputs: v1alpha1.TaskRunInputs{
-				Params: []v1alpha1.Param{
-					// See description of the parameters within the Tasks
-					// We only override parameters here. Defaults are defined within the Tasks
-					{Name: "baseImage", Value: v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: baseImage(c)}},
-					{Name: "moduleDirName", Value: v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: moduleDirName(c)}},
-					{Name: "contextPath", Value: v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: contextPath(c)}},
-				},
+				Params: params,
 				Resources: []v1alpha1.TaskResourceBinding{{
 					PipelineResourceBinding: v1alpha1.PipelineResourceBinding{
 						Name: "git",
 						ResourceSpec: &v1alpha1.PipelineResourceSpec{
-							Type: "git",
-							Params: []v1alpha1.ResourceParam{
-								{
-									Name:  "revision",
-									Value: gitRevision(c),
-								},
-								{
-									Name:  "url",
-									Value: c.Spec.BuildConfig.URL},
-							},
+							Type:   "git",
+							Params: gitParams,
 						},
 					},
 				}},
@@ -83,16 +212,35 @@ func (res taskRun) Build(empty bool) (runtime.Object, error) {
 					},
 				}},
 			},
-		}
+		},
 	}
-
-	return taskRun, nil
 }
 
 func (res taskRun) GetCondition(underlying runtime.Object, err error) *beta1.DependentCondition {
 	return framework.DefaultCustomizedGetConditionFor(res, err, underlying, func(underlying runtime.Object, cond *beta1.DependentCondition) {
-		tr := underlying.(*v1alpha1.TaskRun)
-		succeeded := tr.Status.GetCondition(apis.ConditionSucceeded)
+		run := asBuildRun(underlying)
+		// should the pod of a Job not be retrievable, the status is reported without the steps and results of the build
+		_ = fetchJobPod(run)
+		c := res.ownerAsComponent()
+		cond.SetAttribute(BuildNameAttributeKey, run.GetName())
+		cond.SetAttribute(BuildExecutorAttributeKey, tektonExecutor)
+		if usesJobExecutor() {
+			cond.SetAttribute(BuildExecutorAttributeKey, jobExecutor)
+		}
+		cond.SetAttribute(BuildRevisionAttributeKey, GitRevision(c))
+		if digest := builtImageDigest(run); len(digest) > 0 {
+			cond.SetAttribute(BuildDigestAttributeKey, digest)
+			cond.SetAttribute(BuildImageAttributeKey, pinnedImage(c, digest))
+		} else {
+			cond.SetAttribute(BuildImageAttributeKey, dockerImageURL(c))
+		}
+		if usage := run.results[cacheUsageResult]; len(usage) > 0 {
+			cond.SetAttribute(BuildCacheUsageAttributeKey, usage)
+		}
+		if run.stages != nil {
+			cond.SetAttribute(BuildStagesAttributeKey, stagesStatus(c, run))
+		}
+		succeeded := run.succeeded
 		if succeeded != nil {
 			cond.Message = succeeded.Message
 			cond.Reason = succeeded.Reason
@@ -102,10 +250,96 @@ func (res taskRun) GetCondition(underlying runtime.Object, err error) *beta1.Dep
 			}
 			if succeeded.IsFalse() {
 				cond.Type = beta1.DependentFailed
+				if len(run.failedStage) > 0 {
+					cond.Message = fmt.Sprintf("pipeline stage '%s' failed", run.failedStage)
+					cond.SetAttribute(BuildFailedStageAttributeKey, run.failedStage)
+				}
+				if failure := getBuildFailure(c, run); failure != nil {
+					if len(run.failedStage) > 0 {
+						cond.Message = fmt.Sprintf("step '%s' of pipeline stage '%s' failed with exit code %d", failure.step, run.failedStage, failure.exitCode)
+					} else {
+						cond.Message = fmt.Sprintf("build step '%s' failed with exit code %d", failure.step, failure.exitCode)
+					}
+					cond.SetAttribute(BuildFailedStepAttributeKey, failure.step)
+					cond.SetAttribute(BuildLogAttributeKey, failure.log)
+				}
 				return
 			}
 		}
 		cond.Type = beta1.DependentPending
-		cond.Message = fmt.Sprintf("%s is not ready", tr.Name)
+		cond.Message = fmt.Sprintf("%s is not ready", run.GetName())
+	})
+}
+
+// stagesStatus summarizes the status of the stages of the specified pipeline build, in the order they run
+func stagesStatus(c *v1beta1.Component, run *buildRun) string {
+	config, _, _ := getPipelineConfig(c)
+	statuses := make([]string, 0, len(config.Stages))
+	for _, stage := range config.Stages {
+		status := "Pending"
+		if succeeded, started := run.stages[stage]; started {
+			status = "Running"
+			if succeeded != nil && succeeded.IsTrue() {
+				status = "Succeeded"
+			} else if succeeded != nil && succeeded.IsFalse() {
+				status = "Failed"
+			}
+		}
+		statuses = append(statuses, stage+": "+status)
+	}
+	return strings.Join(statuses, ", ")
+}
+
+// builtImageDigest returns the digest of the image pushed by the specified build as reported by the build strategy, or
+// an empty string if the build didn't report it (yet)
+func builtImageDigest(run *buildRun) string {
+	return run.results[digestResult]
+}
+
+// pinnedImage returns the reference of the component's image with the specified digest
+func pinnedImage(c *v1beta1.Component, digest string) string {
+	image := dockerImageURL(c)
+	// a digest reference doesn't include the tag
+	if slash, colon := strings.LastIndex(image, "/"), strings.LastIndex(image, ":"); colon > slash {
+		image = image[:colon]
+	}
+	return image + "@" + digest
+}
+
+// maxBuildHistory is the number of TaskRuns kept for each component, older ones being deleted
+const maxBuildHistory = 5
+
+// pruneBuildHistory deletes the oldest TaskRuns associated with the specified component so that at most maxBuildHistory
+// remain, the current one always being kept
+func pruneBuildHistory(c *v1beta1.Component) error {
+	runs, err := listBuildRuns(c)
+	if err != nil {
+		return err
+	}
+	// builds can also be deleted by other means
+	forgetBuildFailures(c, runs...)
+	if len(runs) <= maxBuildHistory {
+		return nil
+	}
+
+	// sort from newest to oldest
+	sort.Slice(runs, func(i, j int) bool {
+		newer, older := runs[i].GetCreationTimestamp(), runs[j].GetCreationTimestamp()
+		return older.Before(&newer)
 	})
+	current := BuildName(c)
+	kept := 0
+	for _, run := range runs {
+		if run.GetName() == current || kept < maxBuildHistory-1 {
+			if run.GetName() != current {
+				kept++
+			}
+			continue
+		}
+		// Jobs orphan their pods unless asked otherwise
+		if err := framework.Helper.Client.Delete(context.TODO(), run.object, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
 }
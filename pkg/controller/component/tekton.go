@@ -0,0 +1,354 @@
+package component
+
+import (
+	"context"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	tektonv1beta1 "halkyon.io/operator/pkg/apis/tekton/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"knative.dev/pkg/apis"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitInitImageEnvVar holds the git-init image cloning the sources of builds generated for the Tekton v1beta1 API, which
+// should match the installed Tekton release
+const GitInitImageEnvVar = "GIT_INIT_IMAGE"
+
+const (
+	// sourceWorkspace is the workspace the sources are cloned into with the v1beta1 API, mounted where the git
+	// PipelineResource clones them with the v1alpha1 API so that strategies don't need to care about the API version
+	sourceWorkspace     = "source"
+	sourceWorkspacePath = "/workspace/git"
+	// defaultGitInitImage is the git-init image of the Tekton release, the first one serving the v1beta1 API with results,
+	// which the sources are cloned with unless GitInitImageEnvVar specifies the one of the installed release
+	defaultGitInitImage = "gcr.io/tekton-releases/github.com/tektoncd/pipeline/cmd/git-init:v0.12.1"
+	// pipelineRunLabel is set by Tekton on the TaskRuns it creates for PipelineRuns
+	pipelineRunLabel = "tekton.dev/pipelineRun"
+)
+
+// tektonDetectionInterval is how often the Tekton API is detected again, so that installing or upgrading Tekton is taken
+// into account, and tektonRetryInterval how soon detection is retried when the cluster couldn't be queried
+const (
+	tektonDetectionInterval = 5 * time.Minute
+	tektonRetryInterval     = 10 * time.Second
+)
+
+var tektonAPI struct {
+	sync.Mutex
+	available bool
+	version   schema.GroupVersion
+	// next is when the Tekton API should be detected again
+	next time.Time
+}
+
+// detectTekton checks whether the cluster serves the Tekton API and in which version, v1beta1 being preferred, detecting
+// it again once tektonDetectionInterval elapsed. The previous detection is kept if the cluster can't be queried, Tekton
+// being considered unavailable until a first detection succeeds so that builds run as Jobs meanwhile.
+func detectTekton() {
+	tektonAPI.Lock()
+	defer tektonAPI.Unlock()
+	now := time.Now()
+	if now.Before(tektonAPI.next) {
+		return
+	}
+	available, version, err := discoverTekton()
+	if err != nil {
+		tektonAPI.next = now.Add(tektonRetryInterval)
+		return
+	}
+	tektonAPI.available, tektonAPI.version = available, version
+	tektonAPI.next = now.Add(tektonDetectionInterval)
+}
+
+// discoverTekton queries the cluster for the version of the Tekton API it serves, if any
+func discoverTekton() (bool, schema.GroupVersion, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(framework.Helper.Config)
+	if err != nil {
+		return false, v1alpha1.SchemeGroupVersion, err
+	}
+	for _, version := range []schema.GroupVersion{tektonv1beta1.SchemeGroupVersion, v1alpha1.SchemeGroupVersion} {
+		resources, err := discoveryClient.ServerResourcesForGroupVersion(version.String())
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return false, v1alpha1.SchemeGroupVersion, err
+		}
+		for _, resource := range resources.APIResources {
+			if resource.Name == "taskruns" {
+				return true, version, nil
+			}
+		}
+	}
+	return false, v1alpha1.SchemeGroupVersion, nil
+}
+
+// isTektonAvailable checks whether Tekton is installed on the cluster
+func isTektonAvailable() bool {
+	detectTekton()
+	tektonAPI.Lock()
+	defer tektonAPI.Unlock()
+	return tektonAPI.available
+}
+
+// tektonGroupVersion returns the version of the Tekton API builds are generated for: v1beta1 if the cluster serves it,
+// v1alpha1 otherwise
+func tektonGroupVersion() schema.GroupVersion {
+	detectTekton()
+	tektonAPI.Lock()
+	defer tektonAPI.Unlock()
+	if tektonAPI.version.Empty() {
+		return v1alpha1.SchemeGroupVersion
+	}
+	return tektonAPI.version
+}
+
+func usesTektonV1beta1() bool {
+	return tektonGroupVersion() == tektonv1beta1.SchemeGroupVersion
+}
+
+// gitInitImage returns the git-init image cloning the sources of builds generated for the Tekton v1beta1 API
+func gitInitImage() string {
+	if image, ok := os.LookupEnv(GitInitImageEnvVar); ok && len(image) > 0 {
+		return image
+	}
+	return defaultGitInitImage
+}
+
+// toV1beta1TaskSpec converts the specified TaskSpec, using the git input and image output PipelineResources, to a v1beta1
+// TaskSpec getting the image URL as parameter and declaring the results reported by the steps. Tasks using the git input
+// clone the sources into the source workspace instead.
+func toV1beta1TaskSpec(spec v1alpha1.TaskSpec) tektonv1beta1.TaskSpec {
+	cloned := hasGitInput(spec)
+	params := make([]v1alpha1.ParamSpec, 0, 10)
+	if cloned {
+		params = append(params,
+			v1alpha1.ParamSpec{Name: "gitUrl", Type: v1alpha1.ParamTypeString, Description: "The URL of the git repository to build"},
+			stringParamSpec("gitRevision", "master", "The git revision to build"),
+			stringParamSpec("submodules", "true", "Whether to initialize and update git submodules"),
+			stringParamSpec("depth", "1", "The depth of the clone, 0 performing a full clone"),
+		)
+	}
+	params = append(params, v1alpha1.ParamSpec{Name: "image", Type: v1alpha1.ParamTypeString, Description: "The URL of the image to push"})
+	if spec.Inputs != nil {
+		params = append(params, spec.Inputs.Params...)
+	}
+
+	variables := strings.NewReplacer(
+		"$(inputs.params.", "$(params.",
+		"$(outputs.resources.image.url)", "$(params.image)",
+		"$(inputs.resources.git.path)", sourceWorkspacePath,
+	)
+	steps := make([]tektonv1beta1.Step, 0, len(spec.Steps)+1)
+	var workspaces []tektonv1beta1.WorkspaceDeclaration
+	if cloned {
+		steps = append(steps, v1alpha1.Step{Container: corev1.Container{
+			// Clone the sources, Tekton providing the credentials of the git secrets linked to the service account
+			Name:    "clone",
+			Image:   gitInitImage(),
+			Command: []string{"/ko-app/git-init"},
+			Args: []string{
+				"-url", "$(params.gitUrl)",
+				"-revision", "$(params.gitRevision)",
+				"-path", sourceWorkspacePath,
+				"-submodules=$(params.submodules)",
+				"-depth", "$(params.depth)",
+			},
+		}})
+		workspaces = []tektonv1beta1.WorkspaceDeclaration{{Name: sourceWorkspace, MountPath: sourceWorkspacePath}}
+	}
+	for _, step := range spec.Steps {
+		converted := step.DeepCopy()
+		replaceVariables(&converted.Container, variables)
+		steps = append(steps, *converted)
+	}
+
+	return tektonv1beta1.TaskSpec{
+		Params:     params,
+		Workspaces: workspaces,
+		Steps:      steps,
+		Volumes:    spec.Volumes,
+		Results: []tektonv1beta1.TaskResult{
+			{Name: digestResult, Description: "The digest of the pushed image"},
+			{Name: cacheUsageResult, Description: "The usage of the build cache"},
+		},
+	}
+}
+
+// replaceVariables replaces the variables found in the fields of the specified step container where Tekton substitutes them
+func replaceVariables(container *corev1.Container, variables *strings.Replacer) {
+	container.Image = variables.Replace(container.Image)
+	container.WorkingDir = variables.Replace(container.WorkingDir)
+	for i := range container.Command {
+		container.Command[i] = variables.Replace(container.Command[i])
+	}
+	for i := range container.Args {
+		container.Args[i] = variables.Replace(container.Args[i])
+	}
+	for i := range container.Env {
+		container.Env[i].Value = variables.Replace(container.Env[i].Value)
+	}
+}
+
+// hasGitInput checks whether the specified TaskSpec works on the sources provided by the git input PipelineResource
+func hasGitInput(spec v1alpha1.TaskSpec) bool {
+	if spec.Inputs == nil {
+		return false
+	}
+	for _, resource := range spec.Inputs.Resources {
+		if resource.Name == "git" {
+			return true
+		}
+	}
+	return false
+}
+
+// buildRun provides a common view of the TaskRuns of both Tekton API versions, of the PipelineRuns and of the Jobs. The pod and steps
+// of a PipelineRun are the ones of the stage that failed, if any, while its results are the ones of the build stage.
+type buildRun struct {
+	metav1.Object
+	object    runtime.Object
+	podName   string
+	succeeded *apis.Condition
+	steps     []v1alpha1.StepState
+	results   map[string]string
+	// stages holds the condition of the stages of a PipelineRun by name, nil for stages that didn't start yet
+	stages      map[string]*apis.Condition
+	failedStage string
+}
+
+// asBuildRun returns a view of the specified TaskRun, whichever its API version, PipelineRun or Job
+func asBuildRun(object runtime.Object) *buildRun {
+	switch tr := object.(type) {
+	case *v1alpha1.TaskRun:
+		results := make(map[string]string, len(tr.Status.ResourcesResult))
+		for _, result := range tr.Status.ResourcesResult {
+			results[result.Key] = result.Value
+		}
+		return &buildRun{Object: tr, object: tr, podName: tr.Status.PodName, succeeded: tr.Status.GetCondition(apis.ConditionSucceeded), steps: tr.Status.Steps, results: results}
+	case *tektonv1beta1.TaskRun:
+		results := make(map[string]string, len(tr.Status.TaskRunResults))
+		for _, result := range tr.Status.TaskRunResults {
+			results[result.Name] = strings.TrimSpace(result.Value)
+		}
+		return &buildRun{Object: tr, object: tr, podName: tr.Status.PodName, succeeded: tr.Status.GetCondition(apis.ConditionSucceeded), steps: tr.Status.Steps, results: results}
+	case *batchv1.Job:
+		return asJobBuildRun(tr)
+	case *tektonv1beta1.PipelineRun:
+		run := &buildRun{Object: tr, object: tr, succeeded: tr.Status.GetCondition(apis.ConditionSucceeded), results: map[string]string{}, stages: map[string]*apis.Condition{}}
+		for _, stage := range tr.Status.TaskRuns {
+			if stage == nil || stage.Status == nil {
+				continue
+			}
+			succeeded := stage.Status.GetCondition(apis.ConditionSucceeded)
+			run.stages[stage.PipelineTaskName] = succeeded
+			if stage.PipelineTaskName == buildStage {
+				for _, result := range stage.Status.TaskRunResults {
+					run.results[result.Name] = strings.TrimSpace(result.Value)
+				}
+			}
+			if succeeded != nil && succeeded.IsFalse() {
+				run.failedStage = stage.PipelineTaskName
+				run.podName = stage.Status.PodName
+				run.steps = stage.Status.Steps
+			}
+		}
+		return run
+	default:
+		return nil
+	}
+}
+
+// isSucceeded checks whether the build completed successfully
+func (b *buildRun) isSucceeded() bool {
+	return b.succeeded != nil && b.succeeded.IsTrue()
+}
+
+// emptyBuildRun returns an empty Job if builds run as Jobs, an empty PipelineRun if the specified component is built by
+// a pipeline and an empty TaskRun of the API version builds are generated for otherwise
+func emptyBuildRun(c *v1beta1.Component) runtime.Object {
+	if usesJobExecutor() {
+		return &batchv1.Job{}
+	}
+	if usesPipeline(c) {
+		return &tektonv1beta1.PipelineRun{}
+	}
+	if usesTektonV1beta1() {
+		return &tektonv1beta1.TaskRun{}
+	}
+	return &v1alpha1.TaskRun{}
+}
+
+// fetchBuildRun retrieves the current build of the specified component, along with the pod of Jobs
+func fetchBuildRun(c *v1beta1.Component) (*buildRun, error) {
+	tr := emptyBuildRun(c)
+	if _, err := framework.Helper.Fetch(BuildName(c), c.Namespace, tr); err != nil {
+		return nil, err
+	}
+	run := asBuildRun(tr)
+	if err := fetchJobPod(run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// listBuildRuns retrieves the Jobs building the specified component if builds run as Jobs, its PipelineRuns if it is
+// built by a pipeline and its TaskRuns otherwise. The TaskRuns created for the stages of PipelineRuns, which inherit their
+// labels, are left out. The pods of Jobs aren't retrieved.
+func listBuildRuns(c *v1beta1.Component) ([]*buildRun, error) {
+	lo := &client.ListOptions{}
+	lo.InNamespace(c.Namespace)
+	lo.MatchingLabels(getBuildLabels(c.Name))
+	runs := make([]*buildRun, 0, maxBuildHistory+1)
+	if usesJobExecutor() {
+		list := &batchv1.JobList{}
+		if err := framework.Helper.Client.List(context.TODO(), lo, list); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			runs = append(runs, asJobBuildRun(&list.Items[i]))
+		}
+		return runs, nil
+	}
+	if usesPipeline(c) {
+		list := &tektonv1beta1.PipelineRunList{}
+		if err := framework.Helper.Client.List(context.TODO(), lo, list); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			runs = append(runs, asBuildRun(&list.Items[i]))
+		}
+		return runs, nil
+	}
+	if usesTektonV1beta1() {
+		list := &tektonv1beta1.TaskRunList{}
+		if err := framework.Helper.Client.List(context.TODO(), lo, list); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			if _, ok := list.Items[i].Labels[pipelineRunLabel]; !ok {
+				runs = append(runs, asBuildRun(&list.Items[i]))
+			}
+		}
+		return runs, nil
+	}
+	list := &v1alpha1.TaskRunList{}
+	if err := framework.Helper.Client.List(context.TODO(), lo, list); err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		runs = append(runs, asBuildRun(&list.Items[i]))
+	}
+	return runs, nil
+}
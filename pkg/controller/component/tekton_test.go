@@ -0,0 +1,175 @@
+package component
+
+import (
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	tektonv1beta1 "halkyon.io/operator/pkg/apis/tekton/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// containerFields returns the fields of the specified container where Tekton substitutes variables
+func containerFields(container corev1.Container) []string {
+	fields := append([]string{container.Image, container.WorkingDir}, container.Command...)
+	fields = append(fields, container.Args...)
+	for _, env := range container.Env {
+		fields = append(fields, env.Value)
+	}
+	return fields
+}
+
+func paramNames(params []v1alpha1.ParamSpec) []string {
+	names := make([]string, 0, len(params))
+	for _, param := range params {
+		names = append(names, param.Name)
+	}
+	return names
+}
+
+func TestToV1beta1TaskSpec(t *testing.T) {
+	cases := []struct {
+		strategy string
+		steps    []string
+		params   []string
+		// step and argument expected to reference the converted variables
+		step     string
+		expected string
+	}{
+		{
+			strategy: "s2i",
+			steps:    []string{"clone", "generate", "build", "push"},
+			params:   []string{"gitUrl", "gitRevision", "submodules", "depth", "image", "contextPath", "verifyTLS", "workspacePath", "baseImage", "moduleDirName"},
+			step:     "generate",
+			expected: "$(params.workspacePath)/$(params.contextPath)",
+		},
+		{
+			strategy: "dockerfile",
+			steps:    []string{"clone", "build", "push"},
+			params:   []string{"gitUrl", "gitRevision", "submodules", "depth", "image", "contextPath", "verifyTLS", "workspacePath", "dockerfile"},
+			step:     "push",
+			expected: "$(params.image)",
+		},
+		{
+			strategy: "buildpacks",
+			steps:    []string{"clone", "prepare", "create", "report-digest"},
+			params:   []string{"gitUrl", "gitRevision", "submodules", "depth", "image", "contextPath", "verifyTLS", "workspacePath", "builderImage"},
+			step:     "create",
+			expected: "$(params.image)",
+		},
+		{
+			strategy: "jib",
+			steps:    []string{"clone", "build"},
+			params:   []string{"gitUrl", "gitRevision", "submodules", "depth", "image", "contextPath", "verifyTLS", "workspacePath", "mavenImage", "moduleDirName"},
+			step:     "build",
+			expected: "$(params.image)",
+		},
+	}
+	for _, c := range cases {
+		strategy, ok := buildStrategies[c.strategy]
+		if !ok {
+			t.Fatalf("unknown '%s' build strategy", c.strategy)
+		}
+		original := strategy.taskSpec()
+		spec := toV1beta1TaskSpec(original)
+
+		steps := make([]string, 0, len(spec.Steps))
+		found := false
+		for _, step := range spec.Steps {
+			steps = append(steps, step.Name)
+			for _, field := range containerFields(step.Container) {
+				if strings.Contains(field, "$(inputs.") || strings.Contains(field, "$(outputs.") {
+					t.Errorf("%s: unconverted variable in '%s' step: %s", c.strategy, step.Name, field)
+				}
+				if step.Name == c.step && strings.Contains(field, c.expected) {
+					found = true
+				}
+			}
+		}
+		if !reflect.DeepEqual(steps, c.steps) {
+			t.Errorf("%s: expected %v steps, got %v", c.strategy, c.steps, steps)
+		}
+		if !found {
+			t.Errorf("%s: expected '%s' step to reference %s", c.strategy, c.step, c.expected)
+		}
+		if params := paramNames(spec.Params); !reflect.DeepEqual(params, c.params) {
+			t.Errorf("%s: expected %v params, got %v", c.strategy, c.params, params)
+		}
+		if clone := spec.Steps[0]; clone.Image != defaultGitInitImage {
+			t.Errorf("%s: expected sources to be cloned with %s, got %+v", c.strategy, defaultGitInitImage, clone.Container)
+		}
+		if len(spec.Workspaces) != 1 || spec.Workspaces[0].Name != sourceWorkspace || spec.Workspaces[0].MountPath != sourceWorkspacePath {
+			t.Errorf("%s: expected sources to be cloned into the '%s' workspace, got %+v", c.strategy, sourceWorkspace, spec.Workspaces)
+		}
+		if len(spec.Results) == 0 || spec.Results[0].Name != digestResult {
+			t.Errorf("%s: expected the digest result to be declared, got %+v", c.strategy, spec.Results)
+		}
+		if !reflect.DeepEqual(spec.Volumes, original.Volumes) {
+			t.Errorf("%s: expected volumes to be kept, got %+v", c.strategy, spec.Volumes)
+		}
+		// the original spec is left untouched
+		if !reflect.DeepEqual(original, strategy.taskSpec()) {
+			t.Errorf("%s: expected the converted spec not to be modified", c.strategy)
+		}
+	}
+}
+
+func TestGitInitImage(t *testing.T) {
+	defer os.Unsetenv(GitInitImageEnvVar)
+	if image := gitInitImage(); image != defaultGitInitImage {
+		t.Errorf("expected default git-init image, got %s", image)
+	}
+	image := "gcr.io/tekton-releases/github.com/tektoncd/pipeline/cmd/git-init:v0.16.3"
+	if err := os.Setenv(GitInitImageEnvVar, image); err != nil {
+		t.Fatal(err)
+	}
+	spec := toV1beta1TaskSpec(buildStrategies["dockerfile"].taskSpec())
+	if clone := spec.Steps[0]; clone.Image != image {
+		t.Errorf("expected sources to be cloned with the configured git-init image, got %s", clone.Image)
+	}
+}
+
+func TestAsBuildRun(t *testing.T) {
+	succeeded := duckv1beta1.Status{Conditions: duckv1beta1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue}}}
+	steps := []v1alpha1.StepState{{Name: "build", ContainerName: "step-build"}}
+
+	alpha := &v1alpha1.TaskRun{}
+	alpha.Name = "fruits-build"
+	alpha.Status.Status = succeeded
+	alpha.Status.PodName = "fruits-build-pod"
+	alpha.Status.Steps = steps
+	alpha.Status.ResourcesResult = []v1alpha1.PipelineResourceResult{{Key: digestResult, Value: "sha256:1234"}}
+
+	beta := &tektonv1beta1.TaskRun{}
+	beta.Name = "fruits-build"
+	beta.Status.Status = succeeded
+	beta.Status.PodName = "fruits-build-pod"
+	beta.Status.Steps = steps
+	beta.Status.TaskRunResults = []tektonv1beta1.TaskRunResult{{Name: digestResult, Value: "sha256:1234\n"}, {Name: cacheUsageResult, Value: "1.2G (60% of the volume)"}}
+
+	for name, object := range map[string]runtime.Object{"v1alpha1": alpha, "v1beta1": beta} {
+		run := asBuildRun(object)
+		if run == nil {
+			t.Fatalf("%s: expected a build run", name)
+		}
+		if run.GetName() != "fruits-build" || run.podName != "fruits-build-pod" || !run.isSucceeded() {
+			t.Errorf("%s: expected succeeded 'fruits-build' run with its pod, got %+v", name, run)
+		}
+		if !reflect.DeepEqual(run.steps, steps) {
+			t.Errorf("%s: expected %+v steps, got %+v", name, steps, run.steps)
+		}
+		if digest := run.results[digestResult]; digest != "sha256:1234" {
+			t.Errorf("%s: expected reported digest, got '%s'", name, digest)
+		}
+	}
+	if usage := asBuildRun(beta).results[cacheUsageResult]; usage != "1.2G (60% of the volume)" {
+		t.Errorf("expected reported cache usage, got '%s'", usage)
+	}
+	if run := asBuildRun(&corev1.Pod{}); run != nil {
+		t.Errorf("expected no build run for a pod, got %+v", run)
+	}
+}
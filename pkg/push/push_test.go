@@ -0,0 +1,173 @@
+package push
+
+import (
+	"archive/tar"
+	"bytes"
+	"halkyon.io/operator/pkg/controller/component"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var target = component.DevModeTarget{
+	Container:   "fruits",
+	MountPaths:  []string{"/deployments", "/usr/src"},
+	Supervisord: "/var/lib/supervisord/bin/supervisord",
+	Programs:    map[string]bool{"build": false, "run": true},
+}
+
+func TestParseComponentPath(t *testing.T) {
+	cases := []struct {
+		path      string
+		namespace string
+		name      string
+		valid     bool
+	}{
+		{path: "/push/demo/fruits", namespace: "demo", name: "fruits", valid: true},
+		{path: "/push/demo/fruits/", namespace: "demo", name: "fruits", valid: true},
+		{path: "/push/demo", valid: false},
+		{path: "/push/demo/fruits/extra", valid: false},
+		{path: "/push/", valid: false},
+	}
+	for _, c := range cases {
+		namespace, name, err := parseComponentPath(c.path)
+		if c.valid != (err == nil) {
+			t.Errorf("expected '%s' validity to be %t, got error: %v", c.path, c.valid, err)
+			continue
+		}
+		if namespace != c.namespace || name != c.name {
+			t.Errorf("expected %s/%s for '%s', got %s/%s", c.namespace, c.name, c.path, namespace, name)
+		}
+	}
+}
+
+func TestParsePushRequest(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected pushRequest
+		valid    bool
+	}{
+		{query: "", expected: pushRequest{path: "/deployments"}, valid: true},
+		{query: "path=/usr/src/app&program=build", expected: pushRequest{path: "/usr/src/app", program: "build"}, valid: true},
+		{query: "delete=old.jar&delete=lib/a.jar", expected: pushRequest{path: "/deployments", deleted: []string{"/deployments/old.jar", "/deployments/lib/a.jar"}}, valid: true},
+		{query: "path=/usr/src/../../etc", valid: false},
+		{query: "path=/deployments-other", valid: false},
+		{query: "delete=../etc/passwd", valid: false},
+		{query: "delete=/etc/passwd", valid: false},
+		{query: "delete=.", valid: false},
+		{query: "program=deploy", valid: false},
+	}
+	for _, c := range cases {
+		query, err := url.ParseQuery(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request, err := parsePushRequest(query, target)
+		if c.valid != (err == nil) {
+			t.Errorf("expected '%s' validity to be %t, got error: %v", c.query, c.valid, err)
+			continue
+		}
+		if c.valid && !reflect.DeepEqual(request, c.expected) {
+			t.Errorf("expected %+v for '%s', got %+v", c.expected, c.query, request)
+		}
+	}
+}
+
+type entry struct {
+	name     string
+	typeflag byte
+	linkname string
+}
+
+func archive(t *testing.T, entries ...entry) []byte {
+	buffer := &bytes.Buffer{}
+	writer := tar.NewWriter(buffer)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0644}
+		var content []byte
+		if e.typeflag == tar.TypeReg {
+			content = []byte("content of " + e.name)
+			header.Size = int64(len(content))
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestCopyArchive(t *testing.T) {
+	cases := []struct {
+		name    string
+		entries []entry
+		files   int
+		valid   bool
+	}{
+		{name: "files", entries: []entry{{name: "./", typeflag: tar.TypeDir}, {name: "./app.jar", typeflag: tar.TypeReg}, {name: "lib/", typeflag: tar.TypeDir}, {name: "lib/a.jar", typeflag: tar.TypeReg}}, files: 2, valid: true},
+		{name: "relative link", entries: []entry{{name: "lib/a.jar", typeflag: tar.TypeReg}, {name: "lib/current.jar", typeflag: tar.TypeSymlink, linkname: "a.jar"}}, files: 1, valid: true},
+		{name: "traversal", entries: []entry{{name: "../escape.sh", typeflag: tar.TypeReg}}, valid: false},
+		{name: "absolute", entries: []entry{{name: "/etc/passwd", typeflag: tar.TypeReg}}, valid: false},
+		{name: "escaping symlink", entries: []entry{{name: "lib/passwd", typeflag: tar.TypeSymlink, linkname: "../../etc/passwd"}}, valid: false},
+		{name: "absolute symlink", entries: []entry{{name: "passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}}, valid: false},
+		{name: "escaping hard link", entries: []entry{{name: "passwd", typeflag: tar.TypeLink, linkname: "../etc/passwd"}}, valid: false},
+	}
+	for _, c := range cases {
+		copied := &bytes.Buffer{}
+		files, err := copyArchive(copied, bytes.NewReader(archive(t, c.entries...)))
+		if c.valid != (err == nil) {
+			t.Errorf("expected '%s' archive validity to be %t, got error: %v", c.name, c.valid, err)
+			continue
+		}
+		if !c.valid {
+			continue
+		}
+		if files != c.files {
+			t.Errorf("expected %d file(s) in '%s' archive, got %d", c.files, c.name, files)
+		}
+		reader := tar.NewReader(copied)
+		for _, e := range c.entries {
+			header, err := reader.Next()
+			if err != nil {
+				t.Fatalf("couldn't read '%s' from copied '%s' archive: %v", e.name, c.name, err)
+			}
+			if header.Name != e.name {
+				t.Errorf("expected '%s' entry in copied '%s' archive, got '%s'", e.name, c.name, header.Name)
+			}
+			if e.typeflag == tar.TypeReg {
+				if content, _ := ioutil.ReadAll(reader); string(content) != "content of "+e.name {
+					t.Errorf("unexpected content for '%s' in copied '%s' archive: %s", e.name, c.name, content)
+				}
+			}
+		}
+	}
+}
+
+func TestProgramState(t *testing.T) {
+	output := "build                            Exited    Oct 19 10:02:11\nrun                              Running   pid 42, uptime 0:01:12\n"
+	cases := map[string]string{"build": "Exited", "run": "Running", "deploy": ""}
+	for program, expected := range cases {
+		if state := programState(output, program); state != expected {
+			t.Errorf("expected '%s' state for '%s' program, got '%s'", expected, program, state)
+		}
+	}
+}
+
+func TestSizeLimitedReader(t *testing.T) {
+	content := strings.Repeat("a", 10)
+	if read, err := ioutil.ReadAll(newSizeLimitedReader(strings.NewReader(content), 10)); err != nil || string(read) != content {
+		t.Errorf("expected content within the limit to be read, got '%s' and error: %v", read, err)
+	}
+	_, err := ioutil.ReadAll(newSizeLimitedReader(strings.NewReader(content), 9))
+	if statusErr, ok := err.(statusError); !ok || statusErr.status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected content exceeding the limit to be rejected, got error: %v", err)
+	}
+}
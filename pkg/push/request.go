@@ -0,0 +1,187 @@
+package push
+
+import (
+	"archive/tar"
+	"fmt"
+	"halkyon.io/operator/pkg/controller/component"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// pushRequest holds what a push asks for once validated against the dev mode target of the component
+type pushRequest struct {
+	// path is the directory the pushed files are extracted to
+	path string
+	// deleted are the paths, relative to path, of the files to delete before extracting the pushed ones
+	deleted []string
+	// program is the supervisord program to trigger once the files were written, if any
+	program string
+}
+
+// statusError is an error reported to clients with the specified HTTP status
+type statusError struct {
+	status  int
+	message string
+}
+
+func (e statusError) Error() string {
+	return e.message
+}
+
+func newStatusError(status int, format string, args ...interface{}) error {
+	return statusError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// parseComponentPath extracts the namespace and name of the component pushed to from the specified request path
+func parseComponentPath(requestPath string) (namespace, name string, err error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(requestPath, Path), "/"), "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", newStatusError(http.StatusNotFound, "pushes must be sent to %s<namespace>/<component>", Path)
+	}
+	return parts[0], parts[1], nil
+}
+
+// parsePushRequest validates the query parameters of a push against the specified dev mode target: the destination path
+// must be one of, or within, the paths where pushed files can be written, deleted paths must stay within the destination
+// and the program must be one of the target's supervisord programs
+func parsePushRequest(query url.Values, target component.DevModeTarget) (pushRequest, error) {
+	request := pushRequest{path: query.Get("path"), program: query.Get("program")}
+	if len(request.path) == 0 && len(target.MountPaths) > 0 {
+		request.path = target.MountPaths[0]
+	}
+	request.path = path.Clean(request.path)
+	allowed := false
+	for _, mountPath := range target.MountPaths {
+		if request.path == mountPath || strings.HasPrefix(request.path, strings.TrimSuffix(mountPath, "/")+"/") {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return request, newStatusError(http.StatusBadRequest, "'%s' path isn't among the paths pushed files can be written to: %s", request.path, strings.Join(target.MountPaths, ", "))
+	}
+	for _, deleted := range query["delete"] {
+		if !isWithinDestination(deleted) {
+			return request, newStatusError(http.StatusBadRequest, "deleted path '%s' must be relative to '%s' and stay within it", deleted, request.path)
+		}
+		request.deleted = append(request.deleted, path.Join(request.path, deleted))
+	}
+	if len(request.program) > 0 {
+		if _, ok := target.Programs[request.program]; !ok {
+			programs := make([]string, 0, len(target.Programs))
+			for name := range target.Programs {
+				programs = append(programs, name)
+			}
+			sort.Strings(programs)
+			return request, newStatusError(http.StatusBadRequest, "unknown '%s' program, must be one of: %s", request.program, strings.Join(programs, ", "))
+		}
+	}
+	return request, nil
+}
+
+// isWithinDestination checks whether the specified relative path stays within the directory it is relative to
+func isWithinDestination(relative string) bool {
+	if len(relative) == 0 || path.IsAbs(relative) {
+		return false
+	}
+	cleaned := path.Clean(relative)
+	return cleaned != "." && cleaned != ".." && !strings.HasPrefix(cleaned, "../")
+}
+
+// copyArchive copies the entries of the specified tar archive to dst, rejecting entries, and links, which would end up
+// outside of the destination directory, and returns the number of files it holds
+func copyArchive(dst io.Writer, src io.Reader) (int, error) {
+	in, out := tar.NewReader(src), tar.NewWriter(dst)
+	files := 0
+	for {
+		header, err := in.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, newStatusError(http.StatusBadRequest, "invalid archive: %s", err.Error())
+		}
+		name := strings.TrimPrefix(header.Name, "./")
+		if name != "" && name != "." && !isWithinDestination(name) {
+			return files, newStatusError(http.StatusBadRequest, "archive entry '%s' would be written outside of the destination", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			if !isWithinDestination(path.Join(path.Dir(name), header.Linkname)) || path.IsAbs(header.Linkname) {
+				return files, newStatusError(http.StatusBadRequest, "archive link '%s' points outside of the destination", header.Name)
+			}
+		case tar.TypeLink:
+			if !isWithinDestination(header.Linkname) {
+				return files, newStatusError(http.StatusBadRequest, "archive link '%s' points outside of the destination", header.Name)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			files++
+		}
+		if err := out.WriteHeader(header); err != nil {
+			return files, err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			return files, err
+		}
+	}
+	return files, out.Close()
+}
+
+// sizeLimitedReader reads at most limit bytes from the underlying reader, failing rather than truncating the content when
+// it holds more, unlike io.LimitReader
+type sizeLimitedReader struct {
+	reader    io.Reader
+	limit     int64
+	remaining int64
+}
+
+func newSizeLimitedReader(reader io.Reader, limit int64) io.Reader {
+	// one more byte than the limit is read to tell whether the content exceeds it
+	return &sizeLimitedReader{reader: reader, limit: limit, remaining: limit + 1}
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining <= 0 {
+		return n, newStatusError(http.StatusRequestEntityTooLarge, "pushed archive exceeds %d bytes once decompressed", r.limit)
+	}
+	return n, err
+}
+
+// programState extracts the state of the specified program from the output of supervisord's ctl status command
+func programState(output, program string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == program {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// tail returns the end of the specified output, bounded to maxOutputLength
+func tail(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxOutputLength {
+		return "..." + output[len(output)-maxOutputLength:]
+	}
+	return output
+}
+
+// contains returns whether the specified values include the given one
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,368 @@
+package push
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	halkyon "halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/controller/component"
+	"io"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/retry"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Path is the prefix of the path on which pushes are received, followed by the namespace and name of the component
+	Path = "/push/"
+	// programTimeout bounds how long programs which don't run along with the pod, e.g. builds, can take to complete
+	programTimeout = 10 * time.Minute
+	// programPollInterval is how often the state of such programs is checked
+	programPollInterval = 2 * time.Second
+	// maxOutputLength is the maximum length of the program output recorded in the push outcome
+	maxOutputLength = 2048
+	// Audience is the audience the bearer tokens of push requests must be bound to, so that tokens issued for the API
+	// server or other services can't be replayed by the push endpoint, nor tokens sent to it replayed elsewhere
+	Audience = "halkyon-push"
+	// DefaultMaxSize is the default maximum size of pushed archives, both as sent and once decompressed
+	DefaultMaxSize = int64(100 << 20)
+)
+
+// Server receives the code pushed to dev mode components as tar archives, writes it into the component's pod and
+// triggers the supervisord program building or running it. Requests are served over TLS since clients authenticate with
+// a bearer token, bound to the push Audience, and must be allowed to exec into the pods of the component's namespace,
+// which is what pushing otherwise requires. Pushed archives larger than maxSize bytes are rejected.
+type Server struct {
+	addr     string
+	certFile string
+	keyFile  string
+	maxSize  int64
+	config   *rest.Config
+	client   client.Client
+	kube     kubernetes.Interface
+	log      logr.Logger
+}
+
+// NewServer creates a Server listening on the specified address, serving TLS with the specified certificate and key files
+// and accepting archives of at most maxSize bytes
+func NewServer(addr, certFile, keyFile string, maxSize int64, config *rest.Config, c client.Client, log logr.Logger) *Server {
+	return &Server{addr: addr, certFile: certFile, keyFile: keyFile, maxSize: maxSize, config: config, client: c, kube: kubernetes.NewForConfigOrDie(config), log: log}
+}
+
+// Start runs the server until the stop channel is closed, implementing controller-runtime's Runnable interface
+func (s *Server) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	// no write timeout since responses are only sent once the triggered program completed
+	srv := &http.Server{Addr: s.addr, Handler: mux, ReadTimeout: 5 * time.Minute}
+
+	errs := make(chan error, 1)
+	go func() {
+		s.log.Info("listening for dev mode pushes on " + s.addr + Path)
+		if err := srv.ListenAndServeTLS(s.certFile, s.keyFile); err != nil && err != http.ErrServerClosed {
+			errs <- err
+		}
+		close(errs)
+	}()
+
+	select {
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	case err := <-errs:
+		return err
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	namespace, name, err := parseComponentPath(r.URL.Path)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	if err := s.authorize(r, namespace); err != nil {
+		s.fail(w, err)
+		return
+	}
+	c := &halkyon.Component{}
+	if err := s.client.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: name}, c); err != nil {
+		if errors.IsNotFound(err) {
+			err = newStatusError(http.StatusNotFound, "'%s' component doesn't exist in '%s' namespace", name, namespace)
+		}
+		s.fail(w, err)
+		return
+	}
+	target, err := component.GetDevModeTarget(c)
+	if err != nil {
+		s.fail(w, newStatusError(http.StatusConflict, err.Error()))
+		return
+	}
+	request, err := parsePushRequest(r.URL.Query(), target)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	pod, err := s.readyPod(c)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	// the size of the archive is bounded as sent and, since a small gzipped archive can expand a lot, once decompressed
+	body := io.Reader(http.MaxBytesReader(w, r.Body, s.maxSize))
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		decompressed, err := gzip.NewReader(body)
+		if err != nil {
+			s.fail(w, newStatusError(http.StatusBadRequest, "invalid gzip content: %s", err.Error()))
+			return
+		}
+		body = newSizeLimitedReader(decompressed, s.maxSize)
+	}
+	outcome := s.push(pod, target, request, body)
+	if err := s.record(c, outcome); err != nil {
+		s.log.Error(err, "couldn't record push outcome on "+namespace+"/"+name)
+	}
+	s.log.Info(fmt.Sprintf("pushed %d file(s) to %s/%s:%s, succeeded: %t", outcome.Files, namespace, pod.Name, outcome.Path, outcome.Succeeded))
+
+	w.Header().Set("Content-Type", "application/json")
+	if !outcome.Succeeded {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	_ = json.NewEncoder(w).Encode(outcome)
+}
+
+// fail reports the specified error to the client, with its status if it has one
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	if statusErr, ok := err.(statusError); ok {
+		http.Error(w, statusErr.message, statusErr.status)
+		return
+	}
+	s.log.Error(err, "couldn't process push")
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// authorize checks that the bearer token of the specified request is bound to the push Audience and identifies a user
+// allowed to exec into the pods of the given namespace. Tokens issued by `oc login` or OIDC providers are bound to the API
+// server and thus rejected, so pushes are typically authenticated with ServiceAccount tokens, the access being checked for
+// the ServiceAccount rather than for the developer who requested its token.
+func (s *Server) authorize(r *http.Request, namespace string) error {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if len(token) == 0 {
+		return newStatusError(http.StatusUnauthorized, "a bearer token is required")
+	}
+	review, err := s.kube.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: []string{Audience}},
+	})
+	if err != nil {
+		return err
+	}
+	// authenticators which don't support audiences report none, the token then being only valid for the API server
+	if !review.Status.Authenticated || !contains(review.Status.Audiences, Audience) {
+		return newStatusError(http.StatusUnauthorized, "invalid bearer token, tokens must be bound to the '%s' audience", Audience)
+	}
+	user := review.Status.User
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+	access, err := s.kube.AuthorizationV1().SubjectAccessReviews().Create(&authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			Groups: user.Groups,
+			UID:    user.UID,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   namespace,
+				Verb:        "create",
+				Resource:    "pods",
+				Subresource: "exec",
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !access.Status.Allowed {
+		return newStatusError(http.StatusForbidden, "'%s' isn't allowed to push to components of '%s' namespace", user.Username, namespace)
+	}
+	return nil
+}
+
+// readyPod returns the pod the code pushed to the specified component is written to, which is the ready pod reported in
+// the component status
+func (s *Server) readyPod(c *halkyon.Component) (*corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	lo := &client.ListOptions{}
+	lo.InNamespace(c.Namespace)
+	lo.MatchingLabels(map[string]string{"app": c.DeploymentName()})
+	if err := s.client.List(context.TODO(), lo, pods); err != nil {
+		return nil, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				return pod, nil
+			}
+		}
+	}
+	return nil, newStatusError(http.StatusConflict, "'%s' component has no ready pod to push to, its status should have the %s reason", c.Name, halkyon.PushReady)
+}
+
+// push deletes the requested files from the specified pod, extracts the pushed archive and triggers the requested
+// program, describing the outcome
+func (s *Server) push(pod *corev1.Pod, target component.DevModeTarget, request pushRequest, archive io.Reader) component.PushOutcome {
+	outcome := component.PushOutcome{
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		Path:    request.path,
+		Deleted: len(request.deleted),
+		Program: request.program,
+	}
+	failed := func(err error, output string) component.PushOutcome {
+		outcome.Message = err.Error()
+		if output = tail(output); len(output) > 0 {
+			outcome.Message += ": " + output
+		}
+		return outcome
+	}
+
+	if len(request.deleted) > 0 {
+		if output, err := s.exec(pod, target.Container, append([]string{"rm", "-rf", "--"}, request.deleted...), nil); err != nil {
+			return failed(fmt.Errorf("couldn't delete files"), output)
+		}
+	}
+
+	// the archive is checked while it is streamed to tar in the pod
+	reader, writer := io.Pipe()
+	files := make(chan int, 1)
+	copyErrs := make(chan error, 1)
+	go func() {
+		count, err := copyArchive(writer, archive)
+		files <- count
+		copyErrs <- err
+		_ = writer.CloseWithError(err)
+	}()
+	output, err := s.exec(pod, target.Container, []string{"tar", "-xmf", "-", "-C", request.path}, reader)
+	_ = reader.Close()
+	outcome.Files = <-files
+	// an invalid or too large archive makes tar fail, the reason being reported rather than tar's output unless tar failed
+	// on its own, closing the pipe
+	if copyErr := <-copyErrs; copyErr != nil && copyErr != io.ErrClosedPipe {
+		return failed(copyErr, "")
+	}
+	if err != nil {
+		return failed(fmt.Errorf("couldn't extract pushed files"), output)
+	}
+
+	if len(request.program) > 0 {
+		output, err := s.runProgram(pod, target, request.program)
+		if err != nil {
+			return failed(err, output)
+		}
+		outcome.Message = tail(output)
+	}
+	outcome.Succeeded = true
+	return outcome
+}
+
+// runProgram triggers the specified supervisord program, restarting programs running along with the pod and waiting for
+// the other ones, e.g. builds, to complete
+func (s *Server) runProgram(pod *corev1.Pod, target component.DevModeTarget, program string) (string, error) {
+	ctl := func(args ...string) (string, error) {
+		return s.exec(pod, target.Container, append([]string{target.Supervisord, "ctl"}, args...), nil)
+	}
+	if target.Programs[program] {
+		// stopping a program which isn't running isn't an error
+		_, _ = ctl("stop", program)
+		return ctl("start", program)
+	}
+	if output, err := ctl("start", program); err != nil {
+		return output, fmt.Errorf("couldn't start '%s' program", program)
+	}
+	deadline := time.Now().Add(programTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(programPollInterval)
+		output, err := ctl("status", program)
+		if err != nil {
+			return output, fmt.Errorf("couldn't retrieve '%s' program status", program)
+		}
+		switch state := programState(output, program); state {
+		case "Starting", "Running":
+			continue
+		case "Exited":
+			return output, nil
+		default:
+			return output, fmt.Errorf("'%s' program ended in '%s' state", program, state)
+		}
+	}
+	return "", fmt.Errorf("'%s' program didn't complete within %s", program, programTimeout)
+}
+
+// exec runs the specified command in the given container of the pod, feeding it stdin if not nil, and returns its
+// combined output
+func (s *Server) exec(pod *corev1.Pod, container string, command []string, stdin io.Reader) (string, error) {
+	req := s.kube.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(s.config, http.MethodPost, req.URL())
+	if err != nil {
+		return "", err
+	}
+	output := &bytes.Buffer{}
+	err = executor.Stream(remotecommand.StreamOptions{Stdin: stdin, Stdout: output, Stderr: output})
+	return output.String(), err
+}
+
+// record stores the outcome of the latest push on the specified component so that it is reported in its status
+func (s *Server) record(c *halkyon.Component, outcome component.PushOutcome) error {
+	value, err := json.Marshal(outcome)
+	if err != nil {
+		return err
+	}
+	key := client.ObjectKey{Namespace: c.Namespace, Name: c.Name}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &halkyon.Component{}
+		if err := s.client.Get(context.TODO(), key, latest); err != nil {
+			return err
+		}
+		if latest.Annotations == nil {
+			latest.Annotations = make(map[string]string, 1)
+		}
+		latest.Annotations[component.LastPushAnnotation] = string(value)
+		return s.client.Update(context.TODO(), latest)
+	})
+}
@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// pushEvent holds the information of a git push, independently of the git server which sent it
+type pushEvent struct {
+	// provider is the git server flavor which sent the event
+	provider string
+	// ref is the fully qualified git reference which was pushed to, e.g. refs/heads/master
+	ref string
+	// commit is the SHA of the commit the reference now points to
+	commit string
+	// repositoryURLs are the URLs the pushed to repository can be cloned from
+	repositoryURLs []string
+}
+
+const (
+	github = "github"
+	gitlab = "gitlab"
+	gitea  = "gitea"
+)
+
+// providerFor identifies which git server sent the specified request, returning an empty string if the request isn't a
+// push event
+func providerFor(header http.Header) string {
+	// Gitea also sends GitHub headers for compatibility purposes so it needs to be checked first
+	if event := header.Get("X-Gitea-Event"); len(event) > 0 {
+		if event == "push" {
+			return gitea
+		}
+		return ""
+	}
+	if header.Get("X-GitHub-Event") == "push" {
+		return github
+	}
+	// tag pushes are reported as distinct events by GitLab while GitHub and Gitea report them as pushes
+	if event := header.Get("X-Gitlab-Event"); event == "Push Hook" || event == "Tag Push Hook" {
+		return gitlab
+	}
+	return ""
+}
+
+// githubPush holds the parts of GitHub and Gitea push payloads we're interested in, both using the same format
+type githubPush struct {
+	Ref        string `json:"ref"`
+	After      string `json:"after"`
+	Deleted    bool   `json:"deleted"`
+	Repository struct {
+		CloneURL string `json:"clone_url"`
+		HTMLURL  string `json:"html_url"`
+		SSHURL   string `json:"ssh_url"`
+		GitURL   string `json:"git_url"`
+	} `json:"repository"`
+}
+
+// gitlabPush holds the parts of GitLab push payloads we're interested in
+type gitlabPush struct {
+	Ref         string `json:"ref"`
+	After       string `json:"after"`
+	CheckoutSHA string `json:"checkout_sha"`
+	Project     struct {
+		WebURL     string `json:"web_url"`
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+	} `json:"project"`
+}
+
+// parsePushEvent extracts the push information from the payload sent by the specified provider, returning nil if the
+// push doesn't result in something to build, e.g. when a branch is deleted
+func parsePushEvent(provider string, payload []byte) (*pushEvent, error) {
+	event := &pushEvent{provider: provider}
+	switch provider {
+	case github, gitea:
+		push := githubPush{}
+		if err := json.Unmarshal(payload, &push); err != nil {
+			return nil, fmt.Errorf("invalid %s push payload: %s", provider, err.Error())
+		}
+		if push.Deleted || isZeroSHA(push.After) {
+			return nil, nil
+		}
+		event.ref = push.Ref
+		event.commit = push.After
+		event.repositoryURLs = nonEmpty(push.Repository.CloneURL, push.Repository.HTMLURL, push.Repository.SSHURL, push.Repository.GitURL)
+	case gitlab:
+		push := gitlabPush{}
+		if err := json.Unmarshal(payload, &push); err != nil {
+			return nil, fmt.Errorf("invalid %s push payload: %s", provider, err.Error())
+		}
+		// checkout_sha is null when the branch or tag is deleted, and is the tagged commit for tag pushes
+		if len(push.CheckoutSHA) == 0 || isZeroSHA(push.After) {
+			return nil, nil
+		}
+		event.ref = push.Ref
+		event.commit = push.CheckoutSHA
+		event.repositoryURLs = nonEmpty(push.Project.GitHTTPURL, push.Project.WebURL, push.Project.GitSSHURL)
+	default:
+		return nil, fmt.Errorf("unsupported '%s' git provider", provider)
+	}
+	if len(event.ref) == 0 || len(event.repositoryURLs) == 0 {
+		return nil, fmt.Errorf("%s push payload is missing the reference or repository URL", provider)
+	}
+	return event, nil
+}
+
+// matchesRepository checks whether the specified URL identifies the pushed to repository
+func (in pushEvent) matchesRepository(repositoryURL string) bool {
+	normalized := normalizeRepositoryURL(repositoryURL)
+	if len(normalized) == 0 {
+		return false
+	}
+	for _, u := range in.repositoryURLs {
+		if normalizeRepositoryURL(u) == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesRef checks whether the specified reference, as used to build a component, was pushed to
+func (in pushEvent) matchesRef(ref string) bool {
+	return in.ref == ref || in.ref == "refs/heads/"+ref || in.ref == "refs/tags/"+ref
+}
+
+// normalizeRepositoryURL reduces the different forms of a repository URL (https, ssh, scp-like, with or without .git
+// suffix or credentials) to a host/path form so that they can be compared
+func normalizeRepositoryURL(repositoryURL string) string {
+	u := strings.TrimSpace(repositoryURL)
+	if len(u) == 0 {
+		return ""
+	}
+	if !strings.Contains(u, "://") {
+		// scp-like syntax, e.g. git@github.com:halkyonio/operator.git
+		if at := strings.Index(u, "@"); at >= 0 {
+			u = u[at+1:]
+		}
+		u = "ssh://" + strings.Replace(u, ":", "/", 1)
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	path := strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".git")
+	return strings.ToLower(parsed.Hostname()) + "/" + path
+}
+
+func isZeroSHA(sha string) bool {
+	return len(sha) > 0 && strings.Trim(sha, "0") == ""
+}
+
+func nonEmpty(values ...string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if len(value) > 0 {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	halkyon "halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/controller/component"
+	"io/ioutil"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+const (
+	// Path is the path on which push webhooks are received
+	Path = "/webhooks/git"
+	// maxPayloadSize is the maximum size of accepted payloads, larger ones being rejected
+	maxPayloadSize = 5 * 1024 * 1024
+)
+
+// Server receives git push webhooks from GitHub, GitLab or Gitea and triggers a new build of the build mode components
+// building from the pushed to repository and reference
+type Server struct {
+	addr   string
+	secret []byte
+	client client.Client
+	log    logr.Logger
+}
+
+// NewServer creates a Server listening on the specified address and only accepting payloads signed with the given secret
+func NewServer(addr string, secret []byte, c client.Client, log logr.Logger) *Server {
+	return &Server{addr: addr, secret: secret, client: c, log: log}
+}
+
+// Start runs the server until the stop channel is closed, implementing controller-runtime's Runnable interface
+func (s *Server) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	srv := &http.Server{Addr: s.addr, Handler: mux, ReadTimeout: 30 * time.Second, WriteTimeout: 30 * time.Second}
+
+	errs := make(chan error, 1)
+	go func() {
+		s.log.Info("listening for git push webhooks on " + s.addr + Path)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errs <- err
+		}
+		close(errs)
+	}()
+
+	select {
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	case err := <-errs:
+		return err
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	provider := providerFor(r.Header)
+	if len(provider) == 0 {
+		// acknowledge other events, e.g. pings, so that git servers don't report the webhook as failing
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	payload, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "couldn't read payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := verifySignature(provider, r.Header, payload, s.secret); err != nil {
+		s.log.Info(fmt.Sprintf("rejecting %s push: %s", provider, err.Error()))
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	event, err := parsePushEvent(provider, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if event == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	triggered, err := s.triggerBuilds(event)
+	if err != nil {
+		s.log.Error(err, "couldn't trigger builds for "+event.ref)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]string{"triggered": triggered})
+}
+
+// triggerBuilds requests a new build of the components matching the push event, returning their namespaced names
+func (s *Server) triggerBuilds(event *pushEvent) ([]string, error) {
+	components := &halkyon.ComponentList{}
+	if err := s.client.List(context.TODO(), &client.ListOptions{}, components); err != nil {
+		return nil, err
+	}
+	triggered := make([]string, 0, 1)
+	for _, c := range matchingComponents(components.Items, event) {
+		if c.Annotations[component.BuildTriggerAnnotation] == event.commit {
+			// a build was already triggered for this commit
+			continue
+		}
+		if c.Annotations == nil {
+			c.Annotations = make(map[string]string, 1)
+		}
+		c.Annotations[component.BuildTriggerAnnotation] = event.commit
+		if err := s.client.Update(context.TODO(), c); err != nil {
+			return triggered, fmt.Errorf("couldn't trigger build of '%s' component in '%s' namespace: %s", c.Name, c.Namespace, err.Error())
+		}
+		name := c.Namespace + "/" + c.Name
+		s.log.Info(fmt.Sprintf("triggered build of %s for %s push to %s at %s", name, event.provider, event.ref, event.commit))
+		triggered = append(triggered, name)
+	}
+	return triggered, nil
+}
+
+// matchingComponents returns the build mode components building from the repository and reference the event pushed to
+func matchingComponents(components []halkyon.Component, event *pushEvent) []*halkyon.Component {
+	matching := make([]*halkyon.Component, 0, 1)
+	for i := range components {
+		c := &components[i]
+		if halkyon.BuildDeploymentMode == c.Spec.DeploymentMode && event.matchesRepository(c.Spec.BuildConfig.URL) && event.matchesRef(component.GitRevision(c)) {
+			matching = append(matching, c)
+		}
+	}
+	return matching
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// verifySignature checks that the payload was sent by a git server knowing the shared secret. GitHub and Gitea sign the
+// payload using HMAC while GitLab sends the secret token itself.
+func verifySignature(provider string, header http.Header, payload, secret []byte) error {
+	switch provider {
+	case github:
+		if signature := header.Get("X-Hub-Signature-256"); len(signature) > 0 {
+			return checkHMAC(sha256.New, secret, payload, strings.TrimPrefix(signature, "sha256="))
+		}
+		if signature := header.Get("X-Hub-Signature"); len(signature) > 0 {
+			return checkHMAC(sha1.New, secret, payload, strings.TrimPrefix(signature, "sha1="))
+		}
+		return fmt.Errorf("missing GitHub signature")
+	case gitea:
+		signature := header.Get("X-Gitea-Signature")
+		if len(signature) == 0 {
+			return fmt.Errorf("missing Gitea signature")
+		}
+		return checkHMAC(sha256.New, secret, payload, signature)
+	case gitlab:
+		token := header.Get("X-Gitlab-Token")
+		if len(token) == 0 {
+			return fmt.Errorf("missing GitLab token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), secret) != 1 {
+			return fmt.Errorf("invalid GitLab token")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported '%s' git provider", provider)
+	}
+}
+
+func checkHMAC(hashFn func() hash.Hash, secret, payload []byte, signature string) error {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %s", err.Error())
+	}
+	mac := hmac.New(hashFn, secret)
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}